@@ -0,0 +1,364 @@
+package httpstub
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pb33f/libopenapi-validator/paths"
+	"github.com/pb33f/libopenapi/arazzo/expression"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"go.yaml.in/yaml/v4"
+)
+
+// defaultCallbackClient fires callbacks and webhooks unless CallbackClient is specified.
+// It has the timeout so that receivers which never respond do not block Close and the cleanup of the test.
+var defaultCallbackClient = &http.Client{Timeout: 30 * time.Second}
+
+type callbackConfig struct {
+	payloads map[string]any
+	delay    time.Duration
+}
+
+type callbackOption func(c *callbackConfig) error
+
+// FireCallbacks fires the callbacks declared by the operation after responding.
+// The URL of the callback is evaluated from the runtime expression (e.g. {$request.body#/callbackUrl}) using the request and the response,
+// and the payload is generated from the request body of the callback operation unless it is specified by CallbackPayload.
+// Callbacks are fired asynchronously, and the cleanup of the test waits for them to complete.
+// Close cancels callbacks still waiting for CallbackDelay or waiting for the response of the receiver.
+func FireCallbacks(opts ...callbackOption) responseExampleOption {
+	return func(c *responseExampleConfig) error {
+		cc, err := newCallbackConfig(opts)
+		if err != nil {
+			return err
+		}
+		c.callbacks = cc
+		return nil
+	}
+}
+
+// CallbackPayload specifies the payload of the callback (or the webhook) named name instead of generating it.
+// string and []byte are sent as they are, and the others are encoded as JSON.
+func CallbackPayload(name string, payload any) callbackOption {
+	return func(c *callbackConfig) error {
+		if name == "" {
+			return errors.New("callback name must not be empty")
+		}
+		c.payloads[name] = payload
+		return nil
+	}
+}
+
+// CallbackDelay delays firing callbacks (or webhooks) by d.
+func CallbackDelay(d time.Duration) callbackOption {
+	return func(c *callbackConfig) error {
+		if d < 0 {
+			return errors.New("callback delay must not be negative")
+		}
+		c.delay = d
+		return nil
+	}
+}
+
+// CallbackClient sets *http.Client to fire callbacks and webhooks (default: *http.Client with 30 seconds timeout).
+func CallbackClient(client *http.Client) Option {
+	return func(c *config) error {
+		c.callbackClient = client
+		return nil
+	}
+}
+
+func newCallbackConfig(opts []callbackOption) (*callbackConfig, error) {
+	cc := &callbackConfig{payloads: map[string]any{}}
+	for _, opt := range opts {
+		if err := opt(cc); err != nil {
+			return nil, err
+		}
+	}
+	return cc, nil
+}
+
+// FireWebhook fires the webhook named name declared in the OpenAPI v3.1 Document to url, and waits for the response.
+// The payload is generated from the request body of the webhook operation unless it is specified by CallbackPayload.
+// It is reported as a test error when the status code of the response is not declared in the webhook operation.
+func (rt *Router) FireWebhook(name, url string, opts ...callbackOption) {
+	rt.t.Helper()
+	cc, err := newCallbackConfig(opts)
+	if err != nil {
+		rt.t.Error(err)
+		return
+	}
+	var pathItem *v3.PathItem
+	for _, spec := range rt.openAPI3Specs {
+		if spec.model.Webhooks == nil {
+			continue
+		}
+		if pi, ok := spec.model.Webhooks.Get(name); ok {
+			pathItem = pi
+			break
+		}
+	}
+	if pathItem == nil {
+		rt.t.Errorf("webhook not found in OpenAPI v3 document: %s", name)
+		return
+	}
+	if !sleepContext(rt.ctx, cc.delay) {
+		// the router is closed while waiting
+		return
+	}
+	for method, op := range pathItem.GetOperations().FromOldest() {
+		if err := rt.fireCallback(cc, name, strings.ToUpper(method), url, op); err != nil && rt.ctx.Err() == nil {
+			rt.t.Errorf("failed to fire webhook %s: %v", name, err)
+		}
+	}
+}
+
+// fireCallbacks fires the callbacks of the operation asynchronously after the delay.
+func (rt *Router) fireCallbacks(cc *callbackConfig, op *v3.Operation, ctx *expression.Context) {
+	if op.Callbacks == nil || op.Callbacks.Len() == 0 {
+		return
+	}
+	rt.callbacksCleanup.Do(func() {
+		if c, ok := rt.t.(interface{ Cleanup(func()) }); ok {
+			// report errors of callbacks before the test finishes even if the router is not closed
+			c.Cleanup(rt.callbacks.Wait)
+		}
+	})
+	rt.callbacks.Go(func() {
+		if !sleepContext(rt.ctx, cc.delay) {
+			// the router is closed while waiting
+			return
+		}
+		for name, cb := range op.Callbacks.FromOldest() {
+			if cb == nil || cb.Expression == nil {
+				continue
+			}
+			for expr, pathItem := range cb.Expression.FromOldest() {
+				url, err := evaluateCallbackURL(expr, ctx)
+				if err != nil {
+					rt.t.Errorf("failed to evaluate URL of callback %s (%s): %v", name, expr, err)
+					continue
+				}
+				for method, cop := range pathItem.GetOperations().FromOldest() {
+					// callbacks canceled by Close are not reported
+					if err := rt.fireCallback(cc, name, strings.ToUpper(method), url, cop); err != nil && rt.ctx.Err() == nil {
+						rt.t.Errorf("failed to fire callback %s: %v", name, err)
+					}
+				}
+			}
+		}
+	})
+}
+
+// fireCallback sends the request of the callback operation to url.
+func (rt *Router) fireCallback(cc *callbackConfig, name, method, url string, op *v3.Operation) error {
+	b, contentType, err := rt.callbackPayload(cc, name, op)
+	if err != nil {
+		return err
+	}
+	// requests are canceled when the router is closed
+	req, err := http.NewRequestWithContext(rt.ctx, method, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	client := rt.callbackClient
+	if client == nil {
+		client = defaultCallbackClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+	if !declaredStatus(op.Responses, res.StatusCode) {
+		return fmt.Errorf("%s %s responded with undeclared status: %d", method, url, res.StatusCode)
+	}
+	return nil
+}
+
+// callbackPayload returns the payload specified by CallbackPayload or generated from the request body of the operation.
+func (rt *Router) callbackPayload(cc *callbackConfig, name string, op *v3.Operation) ([]byte, string, error) {
+	var (
+		mt          *v3.MediaType
+		contentType string
+	)
+	if op.RequestBody != nil && op.RequestBody.Content != nil {
+		if tmp, ok := op.RequestBody.Content.Get("application/json"); ok {
+			mt, contentType = tmp, "application/json"
+		} else if p := op.RequestBody.Content.Oldest(); p != nil {
+			mt, contentType = p.Value, p.Key
+		}
+	}
+	if payload, ok := cc.payloads[name]; ok {
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		switch v := payload.(type) {
+		case string:
+			return []byte(v), contentType, nil
+		case []byte:
+			return v, contentType, nil
+		case nil:
+			return nil, contentType, nil
+		default:
+			b, err := json.Marshal(v)
+			return b, contentType, err
+		}
+	}
+	if mt == nil {
+		return nil, "", nil
+	}
+	var node *yaml.Node
+	if rt.responseMode != AlwaysGenerate {
+		m := &matcher{router: rt}
+		_, node, _, _ = m.selectExample(mt, 0, contentType)
+	}
+	if node == nil {
+		if mt.Schema == nil {
+			return nil, "", fmt.Errorf("no schema available to generate payload of callback %s", name)
+		}
+		b, err := rt.generator.generateJSON(mt.Schema.Schema())
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to generate payload of callback %s: %w", name, err)
+		}
+		node = &yaml.Node{}
+		if err := yaml.Unmarshal(b, node); err != nil {
+			return nil, "", err
+		}
+	}
+	b, err := encodeBody(node, mt.Schema, contentType)
+	if err != nil {
+		return nil, "", err
+	}
+	return b, contentType, nil
+}
+
+// callbackContext returns the context to evaluate runtime expressions of callbacks.
+// orig is the request received by the router, and r is the request to the document.
+func callbackContext(orig, r *http.Request, doc *v3.Document, pathTemplate string, status int, header http.Header, body []byte) *expression.Context {
+	scheme := "http"
+	if orig.TLS != nil {
+		scheme = "https"
+	}
+	ctx := &expression.Context{
+		URL:             fmt.Sprintf("%s://%s%s", scheme, orig.Host, orig.URL.RequestURI()),
+		Method:          r.Method,
+		StatusCode:      status,
+		RequestHeaders:  expressionHeaders(r.Header),
+		RequestQuery:    map[string]string{},
+		RequestPath:     pathParams(paths.StripRequestPath(r, doc), pathTemplate),
+		ResponseHeaders: expressionHeaders(header),
+	}
+	for k, v := range r.URL.Query() {
+		if len(v) > 0 {
+			ctx.RequestQuery[k] = v[0]
+		}
+	}
+	if r.Body != nil {
+		b, err := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		if err == nil {
+			ctx.RequestBody = bodyNode(b)
+		}
+	}
+	ctx.ResponseBody = bodyNode(body)
+	return ctx
+}
+
+// expressionHeaders returns the headers keyed by both the canonical and the lower-cased names,
+// since header names in runtime expressions are case-insensitive.
+func expressionHeaders(h http.Header) map[string]string {
+	headers := map[string]string{}
+	for k := range h {
+		headers[k] = h.Get(k)
+		headers[strings.ToLower(k)] = h.Get(k)
+	}
+	return headers
+}
+
+// bodyNode parses the JSON (or YAML) body. It returns nil if the body can not be parsed.
+func bodyNode(b []byte) *yaml.Node {
+	if len(b) == 0 {
+		return nil
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(b, &node); err != nil {
+		return nil
+	}
+	return &node
+}
+
+// evaluateCallbackURL evaluates the runtime expression of the callback, such as
+// {$request.body#/callbackUrl} or https://example.com/notify?id={$response.body#/id}.
+func evaluateCallbackURL(expr string, ctx *expression.Context) (string, error) {
+	if strings.HasPrefix(expr, "$") {
+		v, err := expression.EvaluateString(expr, ctx)
+		if err != nil {
+			return "", err
+		}
+		return expressionString(v)
+	}
+	tokens, err := expression.ParseEmbedded(expr)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, t := range tokens {
+		if !t.IsExpression {
+			sb.WriteString(t.Literal)
+			continue
+		}
+		v, err := expression.Evaluate(t.Expression, ctx)
+		if err != nil {
+			return "", err
+		}
+		s, err := expressionString(v)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(s)
+	}
+	return sb.String(), nil
+}
+
+func expressionString(v any) (string, error) {
+	switch vv := v.(type) {
+	case string:
+		return vv, nil
+	case int:
+		return strconv.Itoa(vv), nil
+	case int64, float64, bool:
+		return fmt.Sprint(vv), nil
+	case *yaml.Node:
+		if vv.Kind == yaml.ScalarNode {
+			return vv.Value, nil
+		}
+	}
+	return "", fmt.Errorf("runtime expression is not evaluated to a scalar value: %v", v)
+}
+
+// declaredStatus reports whether the status code is declared in the responses.
+// Operations without responses accept any status code.
+func declaredStatus(responses *v3.Responses, status int) bool {
+	if responses == nil || responses.Default != nil || responses.Codes == nil || responses.Codes.Len() == 0 {
+		return true
+	}
+	code := strconv.Itoa(status)
+	for c := range responses.Codes.KeysFromOldest() {
+		if c == code || strings.EqualFold(c, code[:1]+"XX") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,247 @@
+package httpstub
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mock_httpstub "github.com/k1LoW/httpstub/mock"
+)
+
+type callbackReceiver struct {
+	status   int
+	requests []string
+	mu       sync.Mutex
+}
+
+func (cr *callbackReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	cr.mu.Lock()
+	cr.requests = append(cr.requests, r.Method+" "+r.URL.Path+" "+r.Header.Get("Content-Type")+" "+string(b))
+	cr.mu.Unlock()
+	w.WriteHeader(cr.status)
+}
+
+// wait waits until the receiver receives n requests.
+func (cr *callbackReceiver) wait(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		cr.mu.Lock()
+		got := len(cr.requests)
+		cr.mu.Unlock()
+		if got >= n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("callbacks are not received: want %v", n)
+}
+
+func TestFireCallbacks(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []callbackOption
+		want string
+	}{
+		{"generated", nil, "POST /hooks/payments/pay_123 application/json {\n  \"status\": \"succeeded\"\n}"},
+		{"payload", []callbackOption{CallbackPayload("paymentCompleted", map[string]any{"status": "failed"})}, `POST /hooks/payments/pay_123 application/json {"status":"failed"}`},
+		{"delay", []callbackOption{CallbackDelay(100 * time.Millisecond), CallbackPayload("paymentCompleted", `{}`)}, `POST /hooks/payments/pay_123 application/json {}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr := &callbackReceiver{status: http.StatusOK}
+			receiver := httptest.NewServer(cr)
+			t.Cleanup(receiver.Close)
+
+			rt := NewRouter(t, OpenApi3("testdata/openapi3-callbacks.yml"))
+			rt.Operation("createPayment").ResponseDynamic(FireCallbacks(tt.opts...))
+			ts := rt.Server()
+			res, err := ts.Client().Post(ts.URL+"/payments", "application/json", strings.NewReader(`{"amount":100,"callbackUrl":"`+receiver.URL+`/hooks"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != http.StatusAccepted {
+				t.Errorf("got %v\nwant %v", res.StatusCode, http.StatusAccepted)
+			}
+			// delayed callbacks are not fired once the router is closed
+			cr.wait(t, 1)
+			rt.Close()
+			cr.mu.Lock()
+			defer cr.mu.Unlock()
+			if len(cr.requests) != 1 {
+				t.Fatalf("got %v\nwant %v", len(cr.requests), 1)
+			}
+			if got := cr.requests[0]; got != tt.want {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFireCallbacksUndeclaredStatus(t *testing.T) {
+	cr := &callbackReceiver{status: http.StatusInternalServerError}
+	receiver := httptest.NewServer(cr)
+	t.Cleanup(receiver.Close)
+
+	ctrl := gomock.NewController(t)
+	m := mock_httpstub.NewMockTB(ctrl)
+	m.EXPECT().Helper().AnyTimes()
+	var cleanup func()
+	m.EXPECT().Cleanup(gomock.Any()).Do(func(fn func()) {
+		cleanup = fn
+	}).Times(1)
+	m.EXPECT().Errorf("failed to fire callback %s: %v", "paymentCompleted", gomock.Any()).Times(1)
+
+	rt := NewRouter(m, OpenApi3("testdata/openapi3-callbacks.yml"))
+	rt.Operation("createPayment").ResponseDynamic(FireCallbacks())
+	ts := rt.Server()
+	res, err := ts.Client().Post(ts.URL+"/payments", "application/json", strings.NewReader(`{"amount":100,"callbackUrl":"`+receiver.URL+`"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	// the cleanup of the test waits for the callback, while Close cancels it
+	cleanup()
+	rt.Close()
+}
+
+func TestFireCallbacksCanceledOnClose(t *testing.T) {
+	cr := &callbackReceiver{status: http.StatusOK}
+	receiver := httptest.NewServer(cr)
+	t.Cleanup(receiver.Close)
+
+	rt := NewRouter(t, OpenApi3("testdata/openapi3-callbacks.yml"))
+	rt.Operation("createPayment").ResponseDynamic(FireCallbacks(CallbackDelay(time.Minute)))
+	ts := rt.Server()
+	res, err := ts.Client().Post(ts.URL+"/payments", "application/json", strings.NewReader(`{"amount":100,"callbackUrl":"`+receiver.URL+`/hooks"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	start := time.Now()
+	rt.Close()
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("got %v\nwant Close not to wait for the delay", elapsed)
+	}
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if len(cr.requests) != 0 {
+		t.Errorf("got %v\nwant %v", len(cr.requests), 0)
+	}
+}
+
+func TestFireCallbacksReceiverNotResponding(t *testing.T) {
+	done := make(chan struct{})
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(func() {
+		close(done)
+		receiver.Close()
+	})
+
+	rt := NewRouter(t, OpenApi3("testdata/openapi3-callbacks.yml"))
+	rt.Operation("createPayment").ResponseDynamic(FireCallbacks())
+	ts := rt.Server()
+	res, err := ts.Client().Post(ts.URL+"/payments", "application/json", strings.NewReader(`{"amount":100,"callbackUrl":"`+receiver.URL+`/hooks"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	start := time.Now()
+	rt.Close()
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("got %v\nwant Close not to wait for the receiver", elapsed)
+	}
+}
+
+func TestFireCallbacksConcurrentlyWithClose(t *testing.T) {
+	cr := &callbackReceiver{status: http.StatusOK}
+	receiver := httptest.NewServer(cr)
+	t.Cleanup(receiver.Close)
+
+	rt := NewRouter(t, OpenApi3("testdata/openapi3-callbacks.yml"))
+	rt.Operation("createPayment").ResponseDynamic(FireCallbacks())
+	ts := rt.Server()
+	tc := ts.Client()
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			// requests may fail since the server is closed
+			res, err := tc.Post(ts.URL+"/payments", "application/json", strings.NewReader(`{"amount":100,"callbackUrl":"`+receiver.URL+`/hooks"}`))
+			if err == nil {
+				res.Body.Close()
+			}
+		})
+	}
+	rt.Close()
+	wg.Wait()
+}
+
+func TestFireCallbacksWaitedOnCleanup(t *testing.T) {
+	cr := &callbackReceiver{status: http.StatusOK}
+	receiver := httptest.NewServer(cr)
+	t.Cleanup(receiver.Close)
+
+	ctrl := gomock.NewController(t)
+	m := mock_httpstub.NewMockTB(ctrl)
+	m.EXPECT().Helper().AnyTimes()
+	var cleanup func()
+	m.EXPECT().Cleanup(gomock.Any()).Do(func(fn func()) {
+		cleanup = fn
+	}).Times(1)
+
+	rt := NewRouter(m, OpenApi3("testdata/openapi3-callbacks.yml"))
+	rt.Operation("createPayment").ResponseDynamic(FireCallbacks(CallbackDelay(100 * time.Millisecond)))
+	ts := httptest.NewServer(rt)
+	t.Cleanup(ts.Close)
+	for range 2 {
+		res, err := ts.Client().Post(ts.URL+"/payments", "application/json", strings.NewReader(`{"amount":100,"callbackUrl":"`+receiver.URL+`/hooks"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+	// the router is not closed, but the cleanup of the test waits for the callbacks
+	cleanup()
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if len(cr.requests) != 2 {
+		t.Errorf("got %v\nwant %v", len(cr.requests), 2)
+	}
+}
+
+func TestFireWebhook(t *testing.T) {
+	cr := &callbackReceiver{status: http.StatusNoContent}
+	receiver := httptest.NewServer(cr)
+	t.Cleanup(receiver.Close)
+
+	rt := NewRouter(t, OpenApi3("testdata/openapi3-callbacks.yml"))
+	rt.FireWebhook("refundCompleted", receiver.URL+"/webhooks")
+	rt.FireWebhook("refundCompleted", receiver.URL+"/webhooks", CallbackPayload("refundCompleted", []byte(`{"status":"succeeded"}`)))
+
+	want := []string{
+		"POST /webhooks application/json {\n  \"status\": \"succeeded\"\n}",
+		`POST /webhooks application/json {"status":"succeeded"}`,
+	}
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if len(cr.requests) != len(want) {
+		t.Fatalf("got %v\nwant %v", len(cr.requests), len(want))
+	}
+	for i, w := range want {
+		if got := cr.requests[i]; got != w {
+			t.Errorf("got %v\nwant %v", got, w)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package httpstub
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var defaultChaosErrorStatuses = []int{
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+type chaosConfig struct {
+	faultRate           float64
+	errorRate           float64
+	errorStatuses       []int
+	tooManyRequestsRate float64
+	retryAfter          time.Duration
+	latencyRate         float64
+	minLatency          time.Duration
+	maxLatency          time.Duration
+}
+
+type chaosOption func(c *chaosConfig) error
+
+// Chaos enables chaos mode, which randomly turns matched responses into failures.
+// Failures are drawn from the router's random number generator, so they are reproducible with Seed.
+func Chaos(opts ...chaosOption) Option {
+	return func(c *config) error {
+		cc := &chaosConfig{}
+		for _, opt := range opts {
+			if err := opt(cc); err != nil {
+				return err
+			}
+		}
+		if cc.faultRate+cc.errorRate+cc.tooManyRequestsRate > 1 {
+			return errors.New("sum of chaos fault, error and too many requests rates must not exceed 1")
+		}
+		c.chaos = cc
+		return nil
+	}
+}
+
+// ChaosServerError returns a 5xx response with the probability of rate.
+// The status code is chosen from statuses (default: 500, 502, 503 and 504).
+func ChaosServerError(rate float64, statuses ...int) chaosOption {
+	return func(c *chaosConfig) error {
+		if err := validateChaosRate(rate); err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			if s < 500 || s > 599 {
+				return fmt.Errorf("invalid chaos server error status: %d", s)
+			}
+		}
+		c.errorRate = rate
+		c.errorStatuses = statuses
+		return nil
+	}
+}
+
+// ChaosTooManyRequests returns a 429 response with Retry-After header with the probability of rate.
+func ChaosTooManyRequests(rate float64, retryAfter time.Duration) chaosOption {
+	return func(c *chaosConfig) error {
+		if err := validateChaosRate(rate); err != nil {
+			return err
+		}
+		if retryAfter < 0 {
+			return errors.New("retryAfter must not be negative")
+		}
+		c.tooManyRequestsRate = rate
+		c.retryAfter = retryAfter
+		return nil
+	}
+}
+
+// ChaosLatency adds latency between min and max to the response with the probability of rate.
+func ChaosLatency(rate float64, minLatency, maxLatency time.Duration) chaosOption {
+	return func(c *chaosConfig) error {
+		if err := validateChaosRate(rate); err != nil {
+			return err
+		}
+		if minLatency < 0 || maxLatency < minLatency {
+			return fmt.Errorf("invalid chaos latency range: %v - %v", minLatency, maxLatency)
+		}
+		c.latencyRate = rate
+		c.minLatency = minLatency
+		c.maxLatency = maxLatency
+		return nil
+	}
+}
+
+// ChaosConnectionFault closes the connection without any response with the probability of rate.
+func ChaosConnectionFault(rate float64) chaosOption {
+	return func(c *chaosConfig) error {
+		if err := validateChaosRate(rate); err != nil {
+			return err
+		}
+		c.faultRate = rate
+		return nil
+	}
+}
+
+func validateChaosRate(rate float64) error {
+	if rate < 0 || rate > 1 {
+		return fmt.Errorf("chaos rate must be between 0 and 1: %v", rate)
+	}
+	return nil
+}
+
+type chaosAction int
+
+const (
+	chaosNone chaosAction = iota
+	chaosFault
+	chaosServerError
+	chaosTooManyRequests
+)
+
+// drawChaos draws the latency and the action to apply to a request from the router's random number generator.
+func (rt *Router) drawChaos(cc *chaosConfig) (time.Duration, chaosAction, int) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	var latency time.Duration
+	if rt.rng.Float64() < cc.latencyRate {
+		latency = cc.minLatency
+		if d := cc.maxLatency - cc.minLatency; d > 0 {
+			latency += time.Duration(rt.rng.Int64N(int64(d) + 1))
+		}
+	}
+	v := rt.rng.Float64()
+	switch {
+	case v < cc.faultRate:
+		return latency, chaosFault, 0
+	case v < cc.faultRate+cc.errorRate:
+		statuses := cc.errorStatuses
+		if len(statuses) == 0 {
+			statuses = defaultChaosErrorStatuses
+		}
+		return latency, chaosServerError, statuses[rt.rng.IntN(len(statuses))]
+	case v < cc.faultRate+cc.errorRate+cc.tooManyRequestsRate:
+		return latency, chaosTooManyRequests, http.StatusTooManyRequests
+	}
+	return latency, chaosNone, 0
+}
+
+func (rt *Router) chaosMiddleware(cc *chaosConfig) middlewareFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			latency, action, status := rt.drawChaos(cc)
+			if !sleepContext(r.Context(), latency) {
+				return
+			}
+			switch action {
+			case chaosFault:
+				conn, _, err := http.NewResponseController(w).Hijack()
+				if err != nil {
+					panic(http.ErrAbortHandler)
+				}
+				_ = conn.Close()
+			case chaosServerError:
+				http.Error(w, http.StatusText(status), status)
+			case chaosTooManyRequests:
+				w.Header().Set("Retry-After", strconv.Itoa(int(cc.retryAfter.Round(time.Second)/time.Second)))
+				http.Error(w, http.StatusText(status), status)
+			default:
+				next.ServeHTTP(w, r)
+			}
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package httpstub
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestChaos(t *testing.T) {
+	tests := []struct {
+		name           string
+		opt            Option
+		wantStatus     int
+		wantRetryAfter string
+		wantErr        bool
+	}{
+		{"no chaos", Chaos(), http.StatusOK, "", false},
+		{"server error", Chaos(ChaosServerError(1, http.StatusServiceUnavailable)), http.StatusServiceUnavailable, "", false},
+		{"too many requests", Chaos(ChaosTooManyRequests(1, 3*time.Second)), http.StatusTooManyRequests, "3", false},
+		{"connection fault", Chaos(ChaosConnectionFault(1)), 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := NewRouter(t, tt.opt)
+			rt.Method(http.MethodGet).Path("/api/v1/users/1").ResponseString(http.StatusOK, `{"name":"alice"}`)
+			ts := rt.Server()
+			t.Cleanup(func() {
+				ts.Close()
+			})
+			tc := ts.Client()
+			res, err := tc.Get("https://example.com/api/v1/users/1")
+			if tt.wantErr {
+				if err == nil {
+					t.Error("want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				res.Body.Close()
+			})
+			if got := res.StatusCode; got != tt.wantStatus {
+				t.Errorf("got %v\nwant %v", got, tt.wantStatus)
+			}
+			if got := res.Header.Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("got %v\nwant %v", got, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func TestChaosLatency(t *testing.T) {
+	rt := NewRouter(t, Chaos(ChaosLatency(1, 100*time.Millisecond, 100*time.Millisecond)))
+	rt.Method(http.MethodGet).Path("/api/v1/users/1").ResponseString(http.StatusOK, `{"name":"alice"}`)
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	tc := ts.Client()
+	start := time.Now()
+	res, err := tc.Get("https://example.com/api/v1/users/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		res.Body.Close()
+	})
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected latency of at least 100ms, got %v", elapsed)
+	}
+	if got := res.StatusCode; got != http.StatusOK {
+		t.Errorf("got %v\nwant %v", got, http.StatusOK)
+	}
+}
+
+func TestChaosSeedDeterministic(t *testing.T) {
+	statuses := func() []int {
+		rt := NewRouter(t, Seed(12345), Chaos(ChaosServerError(0.3), ChaosTooManyRequests(0.3, time.Second)))
+		rt.Method(http.MethodGet).Path("/api/v1/users/1").ResponseString(http.StatusOK, `{"name":"alice"}`)
+		ts := rt.Server()
+		t.Cleanup(func() {
+			ts.Close()
+		})
+		tc := ts.Client()
+		var got []int
+		for range 20 {
+			res, err := tc.Get("https://example.com/api/v1/users/1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = res.Body.Close()
+			got = append(got, res.StatusCode)
+		}
+		return got
+	}
+	s1 := statuses()
+	s2 := statuses()
+	for i := range s1 {
+		if s1[i] != s2[i] {
+			t.Fatalf("expected same statuses with same seed, got:\n%v\n%v", s1, s2)
+		}
+	}
+	ok := 0
+	for _, s := range s1 {
+		if s == http.StatusOK {
+			ok++
+		}
+	}
+	if ok == 0 || ok == len(s1) {
+		t.Errorf("expected a mix of successful and failed responses, got %v", s1)
+	}
+}
+
+func TestChaosInvalidRates(t *testing.T) {
+	c := &config{}
+	if err := Chaos(ChaosServerError(1.5))(c); err == nil {
+		t.Error("want error for rate greater than 1")
+	}
+	if err := Chaos(ChaosServerError(0.6), ChaosConnectionFault(0.6))(c); err == nil {
+		t.Error("want error for rates exceeding 1 in total")
+	}
+	if err := Chaos(ChaosServerError(0.5, http.StatusOK))(c); err == nil {
+		t.Error("want error for non 5xx status")
+	}
+}
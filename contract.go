@@ -0,0 +1,479 @@
+package httpstub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	vconfig "github.com/pb33f/libopenapi-validator/config"
+	verrors "github.com/pb33f/libopenapi-validator/errors"
+	"github.com/pb33f/libopenapi-validator/paths"
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"github.com/pb33f/libopenapi/orderedmap"
+)
+
+// contractIgnoredHeaders is request headers not reported as undocumented parameters.
+// Headers controlling HTTP itself and Prefer (which controls httpstub) are not a part of the contract.
+var contractIgnoredHeaders = []string{
+	"Accept",
+	"Accept-Encoding",
+	"Accept-Language",
+	"Authorization",
+	"Cache-Control",
+	"Connection",
+	"Content-Length",
+	"Content-Type",
+	"Cookie",
+	"Host",
+	"Prefer",
+	"User-Agent",
+}
+
+// maxContractSchemaDepth is the maximum depth of schema properties in the contract report.
+const maxContractSchemaDepth = 10
+
+// ContractReport is a report of contract drift between the exchanges journaled by the router and OpenAPI v3 Document.
+type ContractReport struct {
+	Exchanges             int                      `json:"exchanges"`
+	Violations            []*ContractViolation     `json:"violations"`
+	UndocumentedParams    []*UndocumentedParameter `json:"undocumentedParameters"`
+	UnexercisedProperties []*UnexercisedProperty   `json:"unexercisedProperties"`
+}
+
+// ContractViolation is a request or a response which violated OpenAPI v3 Document.
+type ContractViolation struct {
+	Method string `json:"method"`
+	Host   string `json:"host,omitempty"`
+	// Path is the path of the request
+	Path string `json:"path"`
+	// Status is the status code of the response
+	Status int `json:"status"`
+	// Target is "request" or "response"
+	Target   string   `json:"target"`
+	Messages []string `json:"messages"`
+}
+
+// UndocumentedParameter is a query parameter or a request header which is not declared in the operation.
+type UndocumentedParameter struct {
+	Method string `json:"method"`
+	Host   string `json:"host,omitempty"`
+	// Path is the templated path of the operation
+	Path string `json:"path"`
+	// In is "query" or "header"
+	In   string `json:"in"`
+	Name string `json:"name"`
+	Hits int    `json:"hits"`
+}
+
+// UnexercisedProperty is a property of the schema of the exercised request body or response which never appeared in the exchanges.
+type UnexercisedProperty struct {
+	Method string `json:"method"`
+	Host   string `json:"host,omitempty"`
+	// Path is the templated path of the operation
+	Path string `json:"path"`
+	// Target is "request" or "response {status}"
+	Target string `json:"target"`
+	// Property is the path of the property such as user.address.city or items[].id
+	Property string `json:"property"`
+}
+
+// contractTarget is the request body or the response of an operation whose properties are reported.
+type contractTarget struct {
+	// name is "request" or "response {status}"
+	name    string
+	request bool
+	schema  *base.Schema
+}
+
+// JournalExchanges sets whether to journal exchanges (requests and responses) for OpenAPI v3 Document to report by ContractReport.
+// Exchanges are not journaled by default since the router keeps all the bodies of them.
+func JournalExchanges(journal bool) Option {
+	return func(c *config) error {
+		c.journalExchanges = journal
+		return nil
+	}
+}
+
+// exchange is a pair of the request and the response journaled by the router.
+type exchange struct {
+	spec    *openAPI3Spec
+	req     *http.Request
+	reqBody []byte
+	status  int
+	header  http.Header
+	body    []byte
+}
+
+// journalMiddleware journals exchanges for documents mounted on the router.
+// Exchanges of matchers with SkipValidation are not journaled since they violate the documents deliberately.
+func (rt *Router) journalMiddleware() middlewareFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			spec, sr := rt.findOpenAPI3Spec(r)
+			if spec == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if m, ok := r.Context().Value(matcherKey{}).(*matcher); ok {
+				m.mu.RLock()
+				skip := m.validation == validationNone
+				m.mu.RUnlock()
+				if skip {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			req := cloneReq(sr)
+			reqBody, _ := io.ReadAll(req.Body)
+			rec := newRecorder(w)
+			next.ServeHTTP(rec, r)
+			status := rec.statusCode
+			if status == 0 {
+				status = http.StatusOK
+			}
+			rt.mu.Lock()
+			defer rt.mu.Unlock()
+			rt.exchanges = append(rt.exchanges, &exchange{
+				spec:    spec,
+				req:     req,
+				reqBody: reqBody,
+				status:  status,
+				header:  rec.Header().Clone(),
+				body:    rec.body.Bytes(),
+			})
+		}
+	}
+}
+
+// ContractReport returns the report of contract drift over all exchanges journaled by the router:
+// requests and responses which violated OpenAPI v3 Document, undocumented query parameters and request headers seen,
+// and properties of the schemas of exercised request bodies and responses which never appeared.
+// It requires JournalExchanges.
+func (rt *Router) ContractReport() *ContractReport {
+	rt.t.Helper()
+	if !rt.journalExchanges {
+		rt.t.Error("exchanges are not journaled: use JournalExchanges(true) to report the contract")
+	}
+	rt.mu.RLock()
+	exchanges := slices.Clone(rt.exchanges)
+	rt.mu.RUnlock()
+
+	r := &ContractReport{
+		Exchanges:             len(exchanges),
+		Violations:            []*ContractViolation{},
+		UndocumentedParams:    []*UndocumentedParameter{},
+		UnexercisedProperties: []*UnexercisedProperty{},
+	}
+	validationOpts := &vconfig.ValidationOptions{RegexCache: &sync.Map{}}
+	params := map[string]*UndocumentedParameter{}
+	type exercised struct {
+		spec   *openAPI3Spec
+		method string
+		path   string
+		// seen is the seen property paths per target
+		seen map[string]map[string]struct{}
+		// targets is the exercised targets in order
+		targets []contractTarget
+	}
+	var operations []*exercised
+	index := map[string]*exercised{}
+	for _, e := range exchanges {
+		r.Violations = append(r.Violations, e.violations()...)
+
+		pathItem, _, pathValue := paths.FindPath(e.newRequest(), e.spec.model, validationOpts)
+		if pathItem == nil {
+			continue
+		}
+		op, ok := pathItem.GetOperations().Get(strings.ToLower(e.req.Method))
+		if !ok {
+			continue
+		}
+		method, path := strings.ToUpper(e.req.Method), e.spec.prefix+pathValue
+		for _, p := range undocumentedParameters(e.spec.model, pathItem, op, e.req) {
+			key := method + " " + e.spec.host + path + " " + p[0] + " " + p[1]
+			if up, ok := params[key]; ok {
+				up.Hits++
+				continue
+			}
+			up := &UndocumentedParameter{Method: method, Host: e.spec.host, Path: path, In: p[0], Name: p[1], Hits: 1}
+			params[key] = up
+			r.UndocumentedParams = append(r.UndocumentedParams, up)
+		}
+
+		key := method + " " + e.spec.host + path
+		o, ok := index[key]
+		if !ok {
+			o = &exercised{spec: e.spec, method: method, path: path, seen: map[string]map[string]struct{}{}}
+			index[key] = o
+			operations = append(operations, o)
+		}
+		see := func(t contractTarget, b []byte) {
+			if _, ok := o.seen[t.name]; !ok {
+				o.seen[t.name] = map[string]struct{}{}
+				o.targets = append(o.targets, t)
+			}
+			var v any
+			if err := json.Unmarshal(b, &v); err == nil {
+				collectValuePaths(v, "", o.seen[t.name])
+			}
+		}
+		if op.RequestBody != nil && len(e.reqBody) > 0 {
+			see(contractTarget{name: "request", request: true, schema: jsonContentSchema(op.RequestBody.Content)}, e.reqBody)
+		}
+		if status, res := operationResponse(op, e.status); res != nil {
+			see(contractTarget{name: "response " + status, schema: jsonContentSchema(res.Content)}, e.body)
+		}
+	}
+	for _, o := range operations {
+		for _, t := range o.targets {
+			for _, p := range collectSchemaPaths(t.schema, "", t.request, map[string]bool{}, 0) {
+				if _, ok := o.seen[t.name][p]; ok {
+					continue
+				}
+				r.UnexercisedProperties = append(r.UnexercisedProperties, &UnexercisedProperty{
+					Method:   o.method,
+					Host:     o.spec.host,
+					Path:     o.path,
+					Target:   t.name,
+					Property: p,
+				})
+			}
+		}
+	}
+	return r
+}
+
+// Drifted reports whether the exchanges drifted from OpenAPI v3 Document (violations or undocumented parameters).
+// Unexercised properties are not regarded as drift.
+func (r *ContractReport) Drifted() bool {
+	return len(r.Violations) > 0 || len(r.UndocumentedParams) > 0
+}
+
+// String returns the text report of contract drift.
+func (r *ContractReport) String() string {
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, "Contract report: %d exchange(s), %d violation(s), %d undocumented parameter(s), %d unexercised property(ies)\n",
+		r.Exchanges, len(r.Violations), len(r.UndocumentedParams), len(r.UnexercisedProperties))
+	if len(r.Violations) > 0 {
+		b.WriteString("Violations:\n")
+		for _, v := range r.Violations {
+			_, _ = fmt.Fprintf(&b, "    %s %s%s %d (%s)\n", v.Method, v.Host, v.Path, v.Status, v.Target)
+			for _, m := range v.Messages {
+				_, _ = fmt.Fprintf(&b, "        %s\n", m)
+			}
+		}
+	}
+	if len(r.UndocumentedParams) > 0 {
+		b.WriteString("Undocumented parameters:\n")
+		for _, p := range r.UndocumentedParams {
+			_, _ = fmt.Fprintf(&b, "    %s %s%s %s %s (%d)\n", p.Method, p.Host, p.Path, p.In, p.Name, p.Hits)
+		}
+	}
+	if len(r.UnexercisedProperties) > 0 {
+		b.WriteString("Unexercised properties:\n")
+		for _, p := range r.UnexercisedProperties {
+			_, _ = fmt.Fprintf(&b, "    %s %s%s %s: %s\n", p.Method, p.Host, p.Path, p.Target, p.Property)
+		}
+	}
+	return b.String()
+}
+
+// newRequest returns the journaled request with the body to be read again.
+func (e *exchange) newRequest() *http.Request {
+	req := e.req.Clone(e.req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(e.reqBody))
+	return req
+}
+
+// violations validates the exchange with the document.
+func (e *exchange) violations() (vs []*ContractViolation) {
+	v := e.spec.acquireValidator()
+	defer func() {
+		e.spec.releaseValidator(v, len(vs) > 0)
+	}()
+	newViolation := func(target string, errs []*verrors.ValidationError) *ContractViolation {
+		cv := &ContractViolation{
+			Method: strings.ToUpper(e.req.Method),
+			Host:   e.spec.host,
+			Path:   e.spec.prefix + e.req.URL.Path,
+			Status: e.status,
+			Target: target,
+		}
+		for _, err := range errs {
+			cv.Messages = append(cv.Messages, err.Message)
+		}
+		return cv
+	}
+	if _, errs := v.ValidateHttpRequest(e.newRequest()); len(errs) > 0 {
+		// the response to the invalid request is not validated as the validator middleware does
+		return []*ContractViolation{newViolation("request", errs)}
+	}
+	res := &http.Response{
+		StatusCode: e.status,
+		Status:     http.StatusText(e.status),
+		Header:     e.header,
+		Body:       io.NopCloser(bytes.NewReader(e.body)),
+	}
+	if _, errs := v.ValidateHttpResponse(e.newRequest(), res); len(errs) > 0 {
+		return []*ContractViolation{newViolation("response", errs)}
+	}
+	return nil
+}
+
+// undocumentedParameters returns query parameters and request headers (pairs of in and name) which are not declared in the operation.
+func undocumentedParameters(doc *v3.Document, pathItem *v3.PathItem, op *v3.Operation, r *http.Request) [][2]string {
+	declared := map[string]bool{}
+	for _, p := range slices.Concat(pathItem.Parameters, op.Parameters) {
+		if p == nil {
+			continue
+		}
+		declared[strings.ToLower(p.In)+" "+strings.ToLower(p.Name)] = true
+	}
+	// API keys of security schemes are declared as well
+	if doc.Components != nil && doc.Components.SecuritySchemes != nil {
+		for _, s := range doc.Components.SecuritySchemes.FromOldest() {
+			if s != nil && strings.EqualFold(s.Type, "apiKey") {
+				declared[strings.ToLower(s.In)+" "+strings.ToLower(s.Name)] = true
+			}
+		}
+	}
+	var undocumented [][2]string
+	var queries []string
+	for k := range r.URL.Query() {
+		queries = append(queries, k)
+	}
+	slices.Sort(queries)
+	for _, k := range queries {
+		if !declared["query "+strings.ToLower(k)] {
+			undocumented = append(undocumented, [2]string{"query", k})
+		}
+	}
+	var headers []string
+	for k := range r.Header {
+		headers = append(headers, k)
+	}
+	slices.Sort(headers)
+	for _, k := range headers {
+		if declared["header "+strings.ToLower(k)] || slices.ContainsFunc(contractIgnoredHeaders, func(h string) bool { return strings.EqualFold(h, k) }) {
+			continue
+		}
+		undocumented = append(undocumented, [2]string{"header", k})
+	}
+	return undocumented
+}
+
+// operationResponse returns the status code (exact, range such as 2XX, then default) and the response of the operation for the status.
+func operationResponse(op *v3.Operation, status int) (string, *v3.Response) {
+	if op.Responses == nil {
+		return "", nil
+	}
+	code := strconv.Itoa(status)
+	if op.Responses.Codes != nil {
+		for _, pattern := range []string{code, code[:1] + "XX"} {
+			for c, res := range op.Responses.Codes.FromOldest() {
+				if strings.EqualFold(c, pattern) {
+					return c, res
+				}
+			}
+		}
+	}
+	if op.Responses.Default != nil {
+		return "default", op.Responses.Default
+	}
+	return "", nil
+}
+
+// jsonContentSchema returns the schema of JSON media type in the content.
+func jsonContentSchema(content *orderedmap.Map[string, *v3.MediaType]) *base.Schema {
+	if content == nil {
+		return nil
+	}
+	for ct, mt := range content.FromOldest() {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			continue
+		}
+		if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+			continue
+		}
+		if mt == nil || mt.Schema == nil {
+			return nil
+		}
+		return mt.Schema.Schema()
+	}
+	return nil
+}
+
+// collectSchemaPaths returns the paths of properties of the schema such as user.address.city or items[].id.
+// readOnly properties of requests and writeOnly properties of responses are skipped.
+func collectSchemaPaths(s *base.Schema, prefix string, request bool, visited map[string]bool, depth int) []string {
+	if s == nil || depth > maxContractSchemaDepth {
+		return nil
+	}
+	if ref := schemaRef(s); ref != "" {
+		if visited[ref] {
+			return nil
+		}
+		visited[ref] = true
+		defer delete(visited, ref)
+	}
+	var paths []string
+	add := func(ps ...string) {
+		for _, p := range ps {
+			if !slices.Contains(paths, p) {
+				paths = append(paths, p)
+			}
+		}
+	}
+	for _, sub := range slices.Concat(s.AllOf, s.OneOf, s.AnyOf) {
+		add(collectSchemaPaths(sub.Schema(), prefix, request, visited, depth+1)...)
+	}
+	if s.Items != nil && s.Items.IsA() && s.Items.A != nil {
+		add(collectSchemaPaths(s.Items.A.Schema(), prefix+"[]", request, visited, depth+1)...)
+	}
+	if s.Properties != nil {
+		for name, proxy := range s.Properties.FromOldest() {
+			ps := proxy.Schema()
+			if ps == nil {
+				continue
+			}
+			if (request && ps.ReadOnly != nil && *ps.ReadOnly) || (!request && ps.WriteOnly != nil && *ps.WriteOnly) {
+				continue
+			}
+			p := name
+			if prefix != "" {
+				p = prefix + "." + name
+			}
+			add(p)
+			add(collectSchemaPaths(ps, p, request, visited, depth+1)...)
+		}
+	}
+	return paths
+}
+
+// collectValuePaths collects the paths of properties of the JSON value in the same notation as collectSchemaPaths.
+func collectValuePaths(v any, prefix string, seen map[string]struct{}) {
+	switch vv := v.(type) {
+	case map[string]any:
+		for k, e := range vv {
+			p := k
+			if prefix != "" {
+				p = prefix + "." + k
+			}
+			seen[p] = struct{}{}
+			collectValuePaths(e, p, seen)
+		}
+	case []any:
+		for _, e := range vv {
+			collectValuePaths(e, prefix+"[]", seen)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package httpstub
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mock_httpstub "github.com/k1LoW/httpstub/mock"
+)
+
+func TestContractReport(t *testing.T) {
+	rt := NewRouter(t, OpenApi3("testdata/openapi3.yml"), SkipValidateResponse(true), JournalExchanges(true))
+	rt.Method(http.MethodPost).Path("/api/v1/users").ResponseString(http.StatusCreated, "")
+	rt.Method(http.MethodGet).Path("/api/v1/users").Query("invalid", "true").Header("Content-Type", "application/json").ResponseString(http.StatusOK, `[{"username":1}]`)
+	rt.Method(http.MethodGet).Path("/api/v1/users").Header("Content-Type", "application/json").ResponseString(http.StatusOK, `[{"username":"alice"}]`)
+	rt.Method(http.MethodGet).Path("/api/v1/users/1").SkipValidation().ResponseString(http.StatusTeapot, "")
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	tc := ts.Client()
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/users?debug=1", strings.NewReader(`{"username":"alice","password":"passw0rd"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Trace-Id", "abc")
+	for _, r := range []*http.Request{
+		req,
+		newRequest(t, http.MethodGet, ts.URL+"/api/v1/users", ""),
+		newRequest(t, http.MethodGet, ts.URL+"/api/v1/users?invalid=true", ""),
+		// not journaled
+		newRequest(t, http.MethodGet, ts.URL+"/api/v1/users/1", ""),
+	} {
+		res, err := tc.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	report := rt.ContractReport()
+	if got, want := report.Exchanges, 3; got != want {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+	if !report.Drifted() {
+		t.Error("want drifted")
+	}
+	if len(report.Violations) != 1 {
+		t.Fatalf("got %v\nwant %v", len(report.Violations), 1)
+	}
+	if got, want := report.Violations[0].Path+" "+report.Violations[0].Target, "/api/v1/users response"; got != want {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+
+	var params []string
+	for _, p := range report.UndocumentedParams {
+		params = append(params, p.Method+" "+p.Path+" "+p.In+" "+p.Name)
+	}
+	wantParams := []string{
+		"POST /users query debug",
+		"POST /users header X-Trace-Id",
+		"GET /users query invalid",
+	}
+	if got, want := strings.Join(params, "\n"), strings.Join(wantParams, "\n"); got != want {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+
+	var props []string
+	for _, p := range report.UnexercisedProperties {
+		props = append(props, p.Method+" "+p.Path+" "+p.Target+" "+p.Property)
+	}
+	if got, want := strings.Join(props, "\n"), "GET /users response 200 [].email"; got != want {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+
+	b, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"exchanges", "violations", "undocumentedParameters", "unexercisedProperties"} {
+		if _, ok := got[k]; !ok {
+			t.Errorf("got %v\nwant key %v", got, k)
+		}
+	}
+	if s := report.String(); !strings.HasPrefix(s, "Contract report: 3 exchange(s), 1 violation(s), 3 undocumented parameter(s), 1 unexercised property(ies)\n") {
+		t.Errorf("got %v", s)
+	}
+}
+
+func TestContractReportNoDrift(t *testing.T) {
+	rt := NewRouter(t, OpenApi3("testdata/openapi3.yml"), JournalExchanges(true))
+	rt.Method(http.MethodGet).Path("/api/v1/users/1").Header("Content-Type", "application/json").ResponseString(http.StatusOK, `{"data":{"username":"alice"}}`)
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	res, err := ts.Client().Get(ts.URL + "/api/v1/users/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	report := rt.ContractReport()
+	if report.Drifted() || len(report.UnexercisedProperties) > 0 {
+		t.Errorf("got %v", report)
+	}
+	if got, want := report.String(), "Contract report: 1 exchange(s), 0 violation(s), 0 undocumented parameter(s), 0 unexercised property(ies)\n"; got != want {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+}
+
+func TestContractReportWithoutJournalExchanges(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockTB := mock_httpstub.NewMockTB(ctrl)
+	mockTB.EXPECT().Helper().AnyTimes()
+	mockTB.EXPECT().Error("exchanges are not journaled: use JournalExchanges(true) to report the contract").Times(1)
+	rt := NewRouter(mockTB, OpenApi3("testdata/openapi3.yml"))
+	rt.Method(http.MethodGet).Path("/api/v1/users/1").Header("Content-Type", "application/json").ResponseString(http.StatusOK, `{"data":{"username":"alice"}}`)
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	res, err := ts.Client().Get(ts.URL + "/api/v1/users/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if got := len(rt.exchanges); got != 0 {
+		t.Errorf("got %v\nwant %v", got, 0)
+	}
+	if got := rt.ContractReport().Exchanges; got != 0 {
+		t.Errorf("got %v\nwant %v", got, 0)
+	}
+}
@@ -0,0 +1,352 @@
+package httpstub
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	vconfig "github.com/pb33f/libopenapi-validator/config"
+	"github.com/pb33f/libopenapi-validator/paths"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+)
+
+// OpenAPICoverageCollector collects which operations, status codes and content types of OpenAPI v3 Document were exercised.
+// It can be shared by multiple routers (e.g. across a package) using CollectOpenAPICoverage.
+type OpenAPICoverageCollector struct {
+	operations []*OperationCoverage
+	index      map[string]*OperationCoverage
+	mu         sync.Mutex
+}
+
+// OpenAPICoverageReport is a coverage report of OpenAPI v3 Document.
+type OpenAPICoverageReport struct {
+	Operations []*OperationCoverage `json:"operations"`
+}
+
+// OperationCoverage is a coverage of an operation.
+type OperationCoverage struct {
+	Method      string              `json:"method"`
+	Host        string              `json:"host,omitempty"`
+	Path        string              `json:"path"`
+	OperationID string              `json:"operationId,omitempty"`
+	Hits        int                 `json:"hits"`
+	Responses   []*ResponseCoverage `json:"responses"`
+}
+
+// ResponseCoverage is a coverage of a response (status code and content type) of an operation.
+// ContentType is empty if the response has no content.
+type ResponseCoverage struct {
+	Status      string `json:"status"`
+	ContentType string `json:"contentType,omitempty"`
+	Hits        int    `json:"hits"`
+}
+
+type openAPICoverageConfig struct {
+	collector *OpenAPICoverageCollector
+	w         io.Writer
+	min       float64
+	hasMin    bool
+}
+
+// NewOpenAPICoverageCollector returns a new collector of OpenAPI coverage.
+func NewOpenAPICoverageCollector() *OpenAPICoverageCollector {
+	return &OpenAPICoverageCollector{
+		index: map[string]*OperationCoverage{},
+	}
+}
+
+// CollectOpenAPICoverage sets the collector which the router also records OpenAPI coverage to.
+// The collector can be shared by multiple routers (e.g. across a package) and checked after all tests (e.g. in TestMain).
+func CollectOpenAPICoverage(collector *OpenAPICoverageCollector) Option {
+	return func(c *config) error {
+		if collector == nil {
+			return errors.New("OpenAPI coverage collector is nil")
+		}
+		c.openAPICoverage().collector = collector
+		return nil
+	}
+}
+
+// PrintOpenAPICoverage prints the OpenAPI coverage report of the router to w at cleanup.
+// The report covers only the operations exercised through the router even if the collector is shared using CollectOpenAPICoverage.
+func PrintOpenAPICoverage(w io.Writer) Option {
+	return func(c *config) error {
+		c.openAPICoverage().w = w
+		return nil
+	}
+}
+
+// MinOpenAPICoverage fails the test at cleanup when the OpenAPI coverage (%) of the router is less than min.
+// The coverage is of only the operations exercised through the router even if the collector is shared using CollectOpenAPICoverage,
+// so that the result does not depend on which tests have run yet.
+func MinOpenAPICoverage(min float64) Option {
+	return func(c *config) error {
+		if min < 0 || min > 100 {
+			return fmt.Errorf("invalid minimum OpenAPI coverage: %v", min)
+		}
+		cc := c.openAPICoverage()
+		cc.min = min
+		cc.hasMin = true
+		return nil
+	}
+}
+
+func (c *config) openAPICoverage() *openAPICoverageConfig {
+	if c.coverage == nil {
+		c.coverage = &openAPICoverageConfig{}
+	}
+	return c.coverage
+}
+
+// OpenAPICoverage returns the OpenAPI coverage report of the operations exercised through the router.
+// Use (*OpenAPICoverageCollector).Report for the report of all the routers sharing the collector.
+func (rt *Router) OpenAPICoverage() *OpenAPICoverageReport {
+	if rt.coverageCollector == nil {
+		return &OpenAPICoverageReport{}
+	}
+	return rt.coverageCollector.Report()
+}
+
+// Report returns the OpenAPI coverage report.
+func (c *OpenAPICoverageCollector) Report() *OpenAPICoverageReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r := &OpenAPICoverageReport{}
+	for _, o := range c.operations {
+		oc := *o
+		oc.Responses = make([]*ResponseCoverage, 0, len(o.Responses))
+		for _, res := range o.Responses {
+			rc := *res
+			oc.Responses = append(oc.Responses, &rc)
+		}
+		r.Operations = append(r.Operations, &oc)
+	}
+	return r
+}
+
+// Coverage returns the percentage of the exercised responses (status code and content type) of all operations.
+func (r *OpenAPICoverageReport) Coverage() float64 {
+	var total, covered int
+	for _, o := range r.Operations {
+		if len(o.Responses) == 0 {
+			total++
+			if o.Hits > 0 {
+				covered++
+			}
+			continue
+		}
+		for _, res := range o.Responses {
+			total++
+			if res.Hits > 0 {
+				covered++
+			}
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(covered) / float64(total) * 100
+}
+
+// String returns the text report of OpenAPI coverage.
+func (r *OpenAPICoverageReport) String() string {
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, "OpenAPI coverage: %.1f%%\n", r.Coverage())
+	for _, o := range r.Operations {
+		_, _ = fmt.Fprintf(&b, "%s %s %s%s", coverageMark(o.Hits), o.Method, o.Host, o.Path)
+		if o.OperationID != "" {
+			_, _ = fmt.Fprintf(&b, " (%s)", o.OperationID)
+		}
+		b.WriteString("\n")
+		for _, res := range o.Responses {
+			_, _ = fmt.Fprintf(&b, "    %s %s", coverageMark(res.Hits), res.Status)
+			if res.ContentType != "" {
+				_, _ = fmt.Fprintf(&b, " %s", res.ContentType)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+func coverageMark(hits int) string {
+	if hits > 0 {
+		return "[x]"
+	}
+	return "[ ]"
+}
+
+// register adds operations and responses of doc mounted on host and prefix to the collector.
+func (c *OpenAPICoverageCollector) register(doc *v3.Document, host, prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if doc.Paths == nil {
+		return
+	}
+	for path, pathItem := range doc.Paths.PathItems.FromOldest() {
+		for method, op := range pathItem.GetOperations().FromOldest() {
+			method = strings.ToUpper(method)
+			key := method + " " + host + prefix + path
+			if _, ok := c.index[key]; ok {
+				continue
+			}
+			o := &OperationCoverage{
+				Method:      method,
+				Host:        host,
+				Path:        prefix + path,
+				OperationID: op.OperationId,
+			}
+			if op.Responses != nil {
+				for status, res := range op.Responses.Codes.FromOldest() {
+					o.Responses = append(o.Responses, newResponseCoverages(status, res)...)
+				}
+				if op.Responses.Default != nil {
+					o.Responses = append(o.Responses, newResponseCoverages("default", op.Responses.Default)...)
+				}
+			}
+			c.operations = append(c.operations, o)
+			c.index[key] = o
+		}
+	}
+}
+
+func newResponseCoverages(status string, res *v3.Response) []*ResponseCoverage {
+	if res.Content == nil || res.Content.Len() == 0 {
+		return []*ResponseCoverage{{Status: status}}
+	}
+	var rcs []*ResponseCoverage
+	for ct := range res.Content.KeysFromOldest() {
+		rcs = append(rcs, &ResponseCoverage{Status: status, ContentType: ct})
+	}
+	return rcs
+}
+
+// hit records the exercised operation and response.
+func (c *OpenAPICoverageCollector) hit(method, host, path string, status int, contentType string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	o, ok := c.index[method+" "+host+path]
+	if !ok {
+		return
+	}
+	o.Hits++
+	if res := findResponseCoverage(o.Responses, status, contentType); res != nil {
+		res.Hits++
+	}
+}
+
+// findResponseCoverage finds the response by the status code (exact, range such as 2XX, then default) and content type.
+func findResponseCoverage(responses []*ResponseCoverage, status int, contentType string) *ResponseCoverage {
+	code := strconv.Itoa(status)
+	for _, pattern := range []string{code, code[:1] + "XX", "default"} {
+		var candidates []*ResponseCoverage
+		for _, res := range responses {
+			if strings.EqualFold(res.Status, pattern) {
+				candidates = append(candidates, res)
+			}
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+		for _, res := range candidates {
+			if res.ContentType == "" || matchMediaRange(res.ContentType, contentType) {
+				return res
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// matchMediaRange reports whether the media type matches the media range such as application/json, application/* or */*.
+func matchMediaRange(mediaRange, mediaType string) bool {
+	mr, _, err := mime.ParseMediaType(mediaRange)
+	if err != nil {
+		return false
+	}
+	mt, _, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return false
+	}
+	if mr == "*/*" || mr == mt {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(mr, "/*"); ok {
+		return strings.HasPrefix(mt, prefix+"/")
+	}
+	return false
+}
+
+func (rt *Router) setOpenAPICoverage(cc *openAPICoverageConfig) error {
+	rt.t.Helper()
+	if len(rt.openAPI3Specs) == 0 {
+		if cc != nil {
+			return errors.New("OpenAPI coverage requires OpenAPI v3 document")
+		}
+		return nil
+	}
+	// collectors are the collector of the router and the shared collector if any
+	collector := NewOpenAPICoverageCollector()
+	collectors := []*OpenAPICoverageCollector{collector}
+	if cc != nil && cc.collector != nil {
+		collectors = append(collectors, cc.collector)
+	}
+	for _, c := range collectors {
+		for _, spec := range rt.openAPI3Specs {
+			c.register(spec.model, spec.host, spec.prefix)
+		}
+	}
+	validationOpts := &vconfig.ValidationOptions{RegexCache: &sync.Map{}}
+	mw := func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			rec := newRecorder(w)
+			next.ServeHTTP(rec, r)
+			spec, sr := rt.findOpenAPI3Spec(r)
+			if spec == nil {
+				return
+			}
+			pathItem, _, path := paths.FindPath(sr, spec.model, validationOpts)
+			if pathItem == nil {
+				return
+			}
+			status := rec.statusCode
+			if status == 0 {
+				status = http.StatusOK
+			}
+			for _, c := range collectors {
+				c.hit(strings.ToUpper(r.Method), spec.host, spec.prefix+path, status, rec.Header().Get("Content-Type"))
+			}
+		}
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.coverageCollector = collector
+	rt.middlewares = append(rt.middlewares, mw)
+	if cc == nil || (cc.w == nil && !cc.hasMin) {
+		return nil
+	}
+	check := func() {
+		report := collector.Report()
+		if cc.w != nil {
+			_, _ = io.WriteString(cc.w, report.String())
+		}
+		if cc.hasMin && report.Coverage() < cc.min {
+			rt.t.Errorf("OpenAPI coverage %.1f%% is less than %.1f%%\n%s", report.Coverage(), cc.min, report)
+		}
+	}
+	if c, ok := rt.t.(interface{ Cleanup(func()) }); ok {
+		c.Cleanup(check)
+	} else {
+		// Close may be called more than once
+		var once sync.Once
+		rt.coverageCheck = func() {
+			once.Do(check)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package httpstub
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mock_httpstub "github.com/k1LoW/httpstub/mock"
+)
+
+func TestOpenAPICoverage(t *testing.T) {
+	rt := NewRouter(t, OpenApi3("testdata/openapi3.yml"))
+	rt.Method(http.MethodGet).Path("/api/v1/users").Header("Content-Type", "application/json").ResponseString(http.StatusOK, `[{"username":"alice"}]`)
+	rt.Method(http.MethodPost).Path("/api/v1/users").ResponseString(http.StatusCreated, ``)
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	tc := ts.Client()
+	for _, req := range []*http.Request{
+		newRequest(t, http.MethodGet, "https://example.com/api/v1/users", ""),
+		newRequest(t, http.MethodGet, "https://example.com/api/v1/users", ""),
+		newRequest(t, http.MethodPost, "https://example.com/api/v1/users", `{"username": "alice", "password": "passw0rd"}`),
+	} {
+		res, err := tc.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	report := rt.OpenAPICoverage()
+	tests := []struct {
+		method      string
+		path        string
+		status      string
+		contentType string
+		want        int
+	}{
+		{http.MethodGet, "/users", "200", "application/json", 2},
+		{http.MethodGet, "/users", "404", "application/json", 0},
+		{http.MethodPost, "/users", "201", "", 1},
+		{http.MethodPost, "/users", "400", "application/json", 0},
+		{http.MethodGet, "/users/{id}", "200", "application/json", 0},
+	}
+	for _, tt := range tests {
+		got := -1
+		for _, o := range report.Operations {
+			if o.Method != tt.method || o.Path != tt.path {
+				continue
+			}
+			for _, res := range o.Responses {
+				if res.Status == tt.status && res.ContentType == tt.contentType {
+					got = res.Hits
+				}
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%s %s %s %s: got %v\nwant %v", tt.method, tt.path, tt.status, tt.contentType, got, tt.want)
+		}
+	}
+	if got := report.Coverage(); got <= 0 || got >= 100 {
+		t.Errorf("got %v\nwant between 0 and 100", got)
+	}
+	if got := report.String(); !strings.Contains(got, "[x] GET /users (listUsers)") || !strings.Contains(got, "[ ] 404 application/json") {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestCollectOpenAPICoverage(t *testing.T) {
+	c := NewOpenAPICoverageCollector()
+	var routers []*Router
+	for _, tt := range []struct {
+		path string
+		body string
+	}{
+		{"/api/v1/users", `[]`},
+		{"/api/v1/users/1", `{}`},
+	} {
+		rt := NewRouter(t, OpenApi3("testdata/openapi3.yml"), CollectOpenAPICoverage(c))
+		rt.Method(http.MethodGet).Path(tt.path).Header("Content-Type", "application/json").ResponseString(http.StatusOK, tt.body)
+		ts := rt.Server()
+		res, err := ts.Client().Get("https://example.com" + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		ts.Close()
+		routers = append(routers, rt)
+	}
+	// the report of the router covers only the operations exercised through it
+	for i, want := range []string{"listUsers", "getUser"} {
+		var got []string
+		for _, o := range routers[i].OpenAPICoverage().Operations {
+			if o.Hits > 0 {
+				got = append(got, o.OperationID)
+			}
+		}
+		if strings.Join(got, ",") != want {
+			t.Errorf("got %v\nwant %v", got, want)
+		}
+	}
+	var got []string
+	for _, o := range c.Report().Operations {
+		if o.Hits > 0 {
+			got = append(got, o.OperationID)
+		}
+	}
+	want := []string{"listUsers", "getUser"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+}
+
+func TestMinOpenAPICoverage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockTB := mock_httpstub.NewMockTB(ctrl)
+	mockTB.EXPECT().Helper().AnyTimes()
+	mockTB.EXPECT().Errorf(gomock.Any(), gomock.Any())
+	var cleanup func()
+	mockTB.EXPECT().Cleanup(gomock.Any()).Do(func(fn func()) {
+		cleanup = fn
+	})
+	buf := new(bytes.Buffer)
+	rt := NewRouter(mockTB, OpenApi3("testdata/openapi3.yml"), MinOpenAPICoverage(50), PrintOpenAPICoverage(buf))
+	rt.Method(http.MethodGet).Path("/api/v1/users").Header("Content-Type", "application/json").ResponseString(http.StatusOK, `[]`)
+	ts := rt.Server()
+	res, err := ts.Client().Get("https://example.com/api/v1/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	rt.Close()
+	cleanup()
+	if got := buf.String(); !strings.HasPrefix(got, "OpenAPI coverage: ") {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestMinOpenAPICoverageWithSharedCollector(t *testing.T) {
+	c := NewOpenAPICoverageCollector()
+	// operations of another test which has not been exercised yet
+	_ = NewRouter(t, OpenApi3At("/other", "testdata/openapi3-no-base-path.yml"), CollectOpenAPICoverage(c))
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockTB := mock_httpstub.NewMockTB(ctrl)
+	mockTB.EXPECT().Helper().AnyTimes()
+	var cleanup func()
+	mockTB.EXPECT().Cleanup(gomock.Any()).Do(func(fn func()) {
+		cleanup = fn
+	})
+	rt := NewRouter(mockTB, OpenApi3("testdata/openapi3-no-base-path.yml"), CollectOpenAPICoverage(c), MinOpenAPICoverage(10))
+	rt.Method(http.MethodGet).Path("/notfound").ResponseString(http.StatusNotFound, ``)
+	rt.Method(http.MethodGet).Path("/ping").Header("Content-Type", "text/plain").ResponseString(http.StatusOK, `pong`)
+	ts := rt.Server()
+	tc := ts.Client()
+	for _, path := range []string{"/notfound", "/ping"} {
+		res, err := tc.Get("https://example.com" + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+	rt.Close()
+	// the coverage of the router satisfies the minimum even though the coverage of the shared collector does not
+	cleanup()
+	if got := c.Report().Coverage(); got >= 10 {
+		t.Errorf("got %v\nwant < 10", got)
+	}
+}
+
+func TestOpenAPICoverageRejectedResponses(t *testing.T) {
+	rt := NewRouter(t, OpenApi3("testdata/openapi3-security.yml"), EnforceSecurity(OAuth2Tokens(map[string][]string{
+		"reader": {"items:read"},
+	})))
+	rt.ResponseDynamic(Status("2*"))
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/items", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer reader")
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("got %v\nwant %v", res.StatusCode, http.StatusForbidden)
+	}
+	// the response rejected by the security middleware is recorded
+	got := -1
+	for _, o := range rt.OpenAPICoverage().Operations {
+		if o.Method != http.MethodPost || o.Path != "/items" {
+			continue
+		}
+		for _, r := range o.Responses {
+			if r.Status == "403" {
+				got = r.Hits
+			}
+		}
+	}
+	if got != 1 {
+		t.Errorf("got %v\nwant %v", got, 1)
+	}
+}
+
+func TestOpenAPICoverageRejectedInvalidRequest(t *testing.T) {
+	rt := NewRouter(t, OpenApi3("testdata/openapi3.yml"), RejectInvalidRequest(http.StatusBadRequest))
+	rt.Method(http.MethodPost).Path("/api/v1/users").ResponseString(http.StatusCreated, "")
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	res, err := ts.Client().Post(ts.URL+"/api/v1/users", "application/json", strings.NewReader(`{"invalid": "alice"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("got %v\nwant %v", res.StatusCode, http.StatusBadRequest)
+	}
+	// the request rejected by the validator middleware is recorded
+	got := -1
+	for _, o := range rt.OpenAPICoverage().Operations {
+		if o.Method == http.MethodPost && o.Path == "/users" {
+			got = o.Hits
+		}
+	}
+	if got != 1 {
+		t.Errorf("got %v\nwant %v", got, 1)
+	}
+}
+
+func TestMinOpenAPICoverageCloseTwice(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockTB := mock_httpstub.NewMockTB(ctrl)
+	mockTB.EXPECT().Helper().AnyTimes()
+	mockTB.EXPECT().Errorf(gomock.Any(), gomock.Any()).Times(1)
+	// TB without Cleanup checks OpenAPI coverage on Close
+	tb := struct{ TB }{mockTB}
+	rt := NewRouter(tb, OpenApi3("testdata/openapi3.yml"), MinOpenAPICoverage(50))
+	_ = rt.Server()
+	rt.Close()
+	rt.Close()
+}
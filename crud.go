@@ -0,0 +1,418 @@
+package httpstub
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	validatorconfig "github.com/pb33f/libopenapi-validator/config"
+	"github.com/pb33f/libopenapi-validator/paths"
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+)
+
+type crudResourceKind int
+
+const (
+	crudCollection crudResourceKind = iota + 1
+	crudItem
+)
+
+// crudResource is a templated path of a collection (/pets) or an item (/pets/{id}) resource.
+type crudResource struct {
+	kind    crudResourceKind
+	idParam string
+	schema  *base.Schema
+}
+
+type crudCollectionStore struct {
+	ids   []string
+	items map[string]map[string]any
+	seq   int
+}
+
+type crudStore struct {
+	collections map[string]*crudCollectionStore
+	mu          sync.Mutex
+}
+
+// ResponseCRUD set handler which emulates the resources of OpenAPI v3 Document using an in-memory store.
+// Pairs of collection and item paths (e.g. /pets and /pets/{id}) are recognized as resources:
+// POST to the collection creates an item (generating an id), GET lists items or fetches an item,
+// PUT and PATCH update an item and DELETE removes an item. Missing items result in 404
+// (or the first 4xx status declared in the operation if 404 is not declared).
+// Requests to the other operations are responded by ResponseDynamic.
+func (m *matcher) ResponseCRUD() {
+	m.ResponseDynamic()
+	fallback := m.handler
+	if fallback == nil {
+		return
+	}
+	resources := map[*openAPI3Spec]map[string]crudResource{}
+	for _, spec := range m.router.openAPI3Specs {
+		resources[spec] = findCRUDResources(spec.model)
+	}
+	store := &crudStore{collections: map[string]*crudCollectionStore{}}
+	validationOpts := &validatorconfig.ValidationOptions{RegexCache: &sync.Map{}}
+	m.handler = func(w http.ResponseWriter, r *http.Request) {
+		spec, sr := m.router.findOpenAPI3Spec(r)
+		if spec == nil {
+			fallback(w, r)
+			return
+		}
+		pathItem, _, pathValue := paths.FindPath(sr, spec.model, validationOpts)
+		res, ok := resources[spec][pathValue]
+		if pathItem == nil || !ok {
+			fallback(w, r)
+			return
+		}
+		op, ok := pathItem.GetOperations().Get(strings.ToLower(r.Method))
+		if !ok {
+			fallback(w, r)
+			return
+		}
+		// the id and the collection are taken from the path to the document,
+		// and collections of documents mounted on different hosts or prefixes are stored separately
+		stripped := strings.TrimSuffix(paths.StripRequestPath(sr, spec.model), "/")
+		namespace := spec.host + spec.prefix
+		switch {
+		case res.kind == crudCollection && r.Method == http.MethodGet && isCRUDList(op):
+			m.crudList(w, op, store, namespace+stripped)
+		case res.kind == crudCollection && r.Method == http.MethodPost:
+			m.crudCreate(w, r, op, store, namespace+stripped, res)
+		case res.kind == crudItem && (r.Method == http.MethodGet || r.Method == http.MethodPut || r.Method == http.MethodPatch || r.Method == http.MethodDelete):
+			collection, id := namespace+stripped[:strings.LastIndex(stripped, "/")], pathParams(stripped, pathValue)[res.idParam]
+			m.crudItem(w, r, op, store, collection, id, res)
+		default:
+			fallback(w, r)
+		}
+	}
+}
+
+// ResponseCRUD set handler which emulates the resources of OpenAPI v3 Document using an in-memory store.
+func (rt *Router) ResponseCRUD() {
+	m := &matcher{
+		matchFuncs: []matchFunc{func(_ *http.Request) bool { return true }},
+		router:     rt,
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.addMatcher(m)
+	m.ResponseCRUD()
+}
+
+func (m *matcher) crudList(w http.ResponseWriter, op *v3.Operation, store *crudStore, path string) {
+	store.mu.Lock()
+	items := []map[string]any{}
+	if c, ok := store.collections[path]; ok {
+		for _, id := range c.ids {
+			items = append(items, c.items[id])
+		}
+	}
+	b, err := json.Marshal(items)
+	store.mu.Unlock()
+	if err != nil {
+		m.router.t.Errorf("failed to marshal items of %s: %v", path, err)
+		return
+	}
+	m.writeCRUDResponse(w, op, crudStatus(op, http.StatusOK), b)
+}
+
+func (m *matcher) crudCreate(w http.ResponseWriter, r *http.Request, op *v3.Operation, store *crudStore, path string, res crudResource) {
+	body, ok := m.readCRUDBody(w, r)
+	if !ok {
+		return
+	}
+	item := m.generateCRUDItem(res.schema)
+	for k, v := range body {
+		if isReadOnlyProperty(res.schema, k) {
+			continue
+		}
+		item[k] = v
+	}
+	idKey := crudIDKey(res)
+
+	store.mu.Lock()
+	c, ok := store.collections[path]
+	if !ok {
+		c = &crudCollectionStore{items: map[string]map[string]any{}}
+		store.collections[path] = c
+	}
+	id, ok := body[idKey]
+	if !ok || isReadOnlyProperty(res.schema, idKey) {
+		// ids supplied by clients are skipped so that existing items are not overwritten
+		for {
+			c.seq++
+			id = crudNewID(res.schema, idKey, c.seq)
+			if _, exists := c.items[crudKey(id)]; !exists {
+				break
+			}
+		}
+		item[idKey] = id
+	}
+	key := crudKey(id)
+	if _, exists := c.items[key]; !exists {
+		c.ids = append(c.ids, key)
+	}
+	c.items[key] = item
+	b, err := json.Marshal(item)
+	store.mu.Unlock()
+	if err != nil {
+		m.router.t.Errorf("failed to marshal item of %s: %v", path, err)
+		return
+	}
+	status := crudStatus(op, http.StatusCreated, http.StatusOK)
+	if isHeaderDeclared(op.Responses, status, "Location") {
+		// the path of the request to the router is stripped of BasePath
+		w.Header().Set("Location", m.router.basePath+strings.TrimSuffix(r.URL.Path, "/")+"/"+key)
+	}
+	m.writeCRUDResponse(w, op, status, b)
+}
+
+func (m *matcher) crudItem(w http.ResponseWriter, r *http.Request, op *v3.Operation, store *crudStore, path, id string, res crudResource) {
+	var body map[string]any
+	if r.Method == http.MethodPut || r.Method == http.MethodPatch {
+		var ok bool
+		body, ok = m.readCRUDBody(w, r)
+		if !ok {
+			return
+		}
+	}
+	store.mu.Lock()
+	var item map[string]any
+	c, ok := store.collections[path]
+	if ok {
+		item, ok = c.items[id]
+	}
+	if !ok {
+		store.mu.Unlock()
+		m.crudNotFound(w, op)
+		return
+	}
+	idKey := crudIDKey(res)
+	switch r.Method {
+	case http.MethodPut:
+		// readOnly properties are kept
+		replaced := map[string]any{}
+		for k, v := range body {
+			if !isReadOnlyProperty(res.schema, k) {
+				replaced[k] = v
+			}
+		}
+		for k, v := range item {
+			if isReadOnlyProperty(res.schema, k) {
+				replaced[k] = v
+			}
+		}
+		replaced[idKey] = item[idKey]
+		item = replaced
+		c.items[id] = item
+	case http.MethodPatch:
+		for k, v := range body {
+			if k == idKey || isReadOnlyProperty(res.schema, k) {
+				continue
+			}
+			item[k] = v
+		}
+	case http.MethodDelete:
+		delete(c.items, id)
+		for i, v := range c.ids {
+			if v == id {
+				c.ids = append(c.ids[:i], c.ids[i+1:]...)
+				break
+			}
+		}
+		store.mu.Unlock()
+		status := crudStatus(op, http.StatusNoContent, http.StatusOK)
+		w.WriteHeader(status)
+		return
+	}
+	b, err := json.Marshal(item)
+	store.mu.Unlock()
+	if err != nil {
+		m.router.t.Errorf("failed to marshal item of %s/%s: %v", path, id, err)
+		return
+	}
+	status := crudStatus(op, http.StatusOK)
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+	m.writeCRUDResponse(w, op, status, b)
+}
+
+// crudNotFound writes 404 response, or the first 4xx response declared in the operation if 404 is not declared
+// since undeclared responses fail response validation. The body is generated from the schema of the response if declared.
+func (m *matcher) crudNotFound(w http.ResponseWriter, op *v3.Operation) {
+	status := crudNotFoundStatus(op)
+	if schema := responseSchema(op.Responses, status, "application/json"); schema != nil {
+		if b, err := m.router.generator.generateJSON(schema); err == nil {
+			m.writeCRUDResponse(w, op, status, b)
+			return
+		}
+	}
+	w.WriteHeader(status)
+}
+
+// crudNotFoundStatus returns 404 if declared (including 4XX and default) in the operation responses, otherwise the first declared 4xx status.
+func crudNotFoundStatus(op *v3.Operation) int {
+	if op.Responses == nil || findResponse(op.Responses, http.StatusNotFound) != nil || op.Responses.Codes == nil {
+		return http.StatusNotFound
+	}
+	for code := range op.Responses.Codes.KeysFromOldest() {
+		if s, err := strconv.Atoi(code); err == nil && s >= 400 && s < 500 {
+			return s
+		}
+	}
+	return http.StatusNotFound
+}
+
+func (m *matcher) readCRUDBody(w http.ResponseWriter, r *http.Request) (map[string]any, bool) {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		m.router.t.Errorf("failed to read request body: %v", err)
+		return nil, false
+	}
+	body := map[string]any{}
+	if len(b) == 0 {
+		return body, true
+	}
+	if err := json.Unmarshal(b, &body); err != nil {
+		http.Error(w, fmt.Sprintf("invalid JSON object: %v", err), http.StatusBadRequest)
+		return nil, false
+	}
+	return body, true
+}
+
+// generateCRUDItem generates an item from schema to fill properties which the request does not have.
+func (m *matcher) generateCRUDItem(schema *base.Schema) map[string]any {
+	item := map[string]any{}
+	if schema == nil {
+		return item
+	}
+	b, err := m.router.generator.generateJSON(schema)
+	if err != nil {
+		return item
+	}
+	_ = json.Unmarshal(b, &item)
+	return item
+}
+
+// findCRUDResources finds pairs of collection and item paths and returns resources by templated path.
+func findCRUDResources(doc *v3.Document) map[string]crudResource {
+	resources := map[string]crudResource{}
+	if doc.Paths == nil {
+		return resources
+	}
+	for path, pathItem := range doc.Paths.PathItems.FromOldest() {
+		i := strings.LastIndex(path, "/")
+		if i < 0 {
+			continue
+		}
+		last := path[i+1:]
+		if !strings.HasPrefix(last, "{") || !strings.HasSuffix(last, "}") || strings.Count(last, "{") != 1 {
+			continue
+		}
+		collection := path[:i]
+		collectionItem, ok := doc.Paths.PathItems.Get(collection)
+		if !ok {
+			continue
+		}
+		idParam := strings.Trim(last, "{}")
+		schema := crudItemSchema(pathItem, collectionItem)
+		resources[path] = crudResource{kind: crudItem, idParam: idParam, schema: schema}
+		resources[collection] = crudResource{kind: crudCollection, idParam: idParam, schema: schema}
+	}
+	return resources
+}
+
+// crudItemSchema returns the schema of the item from the GET response of the item path or the POST response of the collection path.
+func crudItemSchema(item, collection *v3.PathItem) *base.Schema {
+	if item.Get != nil && item.Get.Responses != nil {
+		if s := responseSchema(item.Get.Responses, http.StatusOK, "application/json"); s != nil {
+			return s
+		}
+	}
+	if collection.Post != nil && collection.Post.Responses != nil {
+		for _, status := range []int{http.StatusCreated, http.StatusOK} {
+			if s := responseSchema(collection.Post.Responses, status, "application/json"); s != nil {
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+// isCRUDList reports whether the operation responds an array of items.
+func isCRUDList(op *v3.Operation) bool {
+	if op.Responses == nil {
+		return false
+	}
+	return echoKind(nil, responseSchema(op.Responses, crudStatus(op, http.StatusOK), "application/json")) == "array"
+}
+
+// isReadOnlyProperty reports whether the property of the schema is readOnly, which requests can not set.
+func isReadOnlyProperty(schema *base.Schema, name string) bool {
+	p := schemaProperty(schema, name)
+	return p != nil && p.ReadOnly != nil && *p.ReadOnly
+}
+
+// crudIDKey returns the property name of the id: the name of the path parameter if the schema has it, otherwise "id".
+func crudIDKey(res crudResource) string {
+	if schemaProperty(res.schema, res.idParam) != nil {
+		return res.idParam
+	}
+	return "id"
+}
+
+// crudNewID returns a new id of the type of the id property.
+func crudNewID(schema *base.Schema, idKey string, seq int) any {
+	switch echoKind(nil, schemaProperty(schema, idKey)) {
+	case "integer", "number":
+		return seq
+	}
+	return strconv.Itoa(seq)
+}
+
+// crudKey returns the key of the id to look up by path parameter.
+func crudKey(id any) string {
+	switch v := id.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// crudStatus returns the first status of candidates declared in the operation responses, or the first declared 2xx status.
+func crudStatus(op *v3.Operation, candidates ...int) int {
+	if op.Responses == nil {
+		return candidates[0]
+	}
+	for _, s := range candidates {
+		if _, ok := op.Responses.Codes.Get(strconv.Itoa(s)); ok {
+			return s
+		}
+	}
+	for code := range op.Responses.Codes.KeysFromOldest() {
+		if s, err := strconv.Atoi(code); err == nil && s >= 200 && s < 300 {
+			return s
+		}
+	}
+	return candidates[0]
+}
+
+func (m *matcher) writeCRUDResponse(w http.ResponseWriter, op *v3.Operation, status int, b []byte) {
+	if op.Responses != nil {
+		if err := m.setResponseHeaders(w, op.Responses, status); err != nil {
+			m.router.t.Errorf("failed to generate response headers: %v", err)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(b)
+}
@@ -0,0 +1,175 @@
+package httpstub
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestResponseCRUD(t *testing.T) {
+	rt := NewRouter(t, OpenApi3("testdata/openapi3-crud.yml"))
+	rt.ResponseCRUD()
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	tc := ts.Client()
+
+	tests := []struct {
+		name         string
+		req          *http.Request
+		wantStatus   int
+		want         map[string]any
+		wantLocation string
+	}{
+		{"create", newRequest(t, http.MethodPost, "https://example.com/api/v1/items", `{"name":"alice"}`), http.StatusCreated, map[string]any{"id": float64(1), "name": "alice"}, "/api/v1/items/1"},
+		{"create another", newRequest(t, http.MethodPost, "https://example.com/api/v1/items", `{"name":"bob"}`), http.StatusCreated, map[string]any{"id": float64(2), "name": "bob"}, "/api/v1/items/2"},
+		{"fetch", newRequest(t, http.MethodGet, "https://example.com/api/v1/items/1", ""), http.StatusOK, map[string]any{"id": float64(1), "name": "alice"}, ""},
+		{"delete", newRequest(t, http.MethodDelete, "https://example.com/api/v1/items/1", ""), http.StatusNoContent, nil, ""},
+		{"fetch deleted", newRequest(t, http.MethodGet, "https://example.com/api/v1/items/1", ""), http.StatusNotFound, nil, ""},
+		{"update", newRequest(t, http.MethodPatch, "https://example.com/api/v1/items/2", `{"name":"carol"}`), http.StatusOK, map[string]any{"id": float64(2), "name": "carol"}, ""},
+		{"replace", newRequest(t, http.MethodPut, "https://example.com/api/v1/items/2", `{"name":"dave"}`), http.StatusOK, map[string]any{"id": float64(2), "name": "dave"}, ""},
+		{"update missing", newRequest(t, http.MethodPatch, "https://example.com/api/v1/items/3", `{"name":"carol"}`), http.StatusNotFound, nil, ""},
+		{"create with id", newRequest(t, http.MethodPost, "https://example.com/api/v1/items", `{"id":3,"name":"erin"}`), http.StatusCreated, map[string]any{"id": float64(3), "name": "erin"}, "/api/v1/items/3"},
+		{"create skips the id supplied", newRequest(t, http.MethodPost, "https://example.com/api/v1/items", `{"name":"frank"}`), http.StatusCreated, map[string]any{"id": float64(4), "name": "frank"}, "/api/v1/items/4"},
+		{"fetch the item created with id", newRequest(t, http.MethodGet, "https://example.com/api/v1/items/3", ""), http.StatusOK, map[string]any{"id": float64(3), "name": "erin"}, ""},
+		{"missing without declared 404", newRequest(t, http.MethodGet, "https://example.com/api/v1/tags/1", ""), http.StatusBadRequest, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tc.Do(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				res.Body.Close()
+			})
+			if got := res.StatusCode; got != tt.wantStatus {
+				t.Errorf("got %v\nwant %v", got, tt.wantStatus)
+			}
+			if got := res.Header.Get("Location"); got != tt.wantLocation {
+				t.Errorf("got %v\nwant %v", got, tt.wantLocation)
+			}
+			if tt.want == nil {
+				return
+			}
+			var got map[string]any
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			for k, want := range tt.want {
+				if got[k] != want {
+					t.Errorf("%s: got %v\nwant %v", k, got[k], want)
+				}
+			}
+		})
+	}
+}
+
+func TestResponseCRUDAt(t *testing.T) {
+	rt := NewRouter(t, OpenApi3At("/svc", "testdata/openapi3-crud.yml"), OpenApi3OnHost("other.example.com", "testdata/openapi3-crud.yml"))
+	rt.ResponseCRUD()
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	tc := ts.Client()
+
+	tests := []struct {
+		name       string
+		req        *http.Request
+		wantStatus int
+		want       map[string]any
+	}{
+		{"create", newRequest(t, http.MethodPost, "https://example.com/svc/api/v1/items", `{"name":"alice"}`), http.StatusCreated, map[string]any{"id": float64(1), "name": "alice"}},
+		{"fetch", newRequest(t, http.MethodGet, "https://example.com/svc/api/v1/items/1", ""), http.StatusOK, map[string]any{"id": float64(1), "name": "alice"}},
+		{"update", newRequest(t, http.MethodPatch, "https://example.com/svc/api/v1/items/1", `{"name":"bob"}`), http.StatusOK, map[string]any{"id": float64(1), "name": "bob"}},
+		{"create on host", newRequest(t, http.MethodPost, "https://other.example.com/api/v1/items", `{"name":"carol"}`), http.StatusCreated, map[string]any{"id": float64(1), "name": "carol"}},
+		{"fetch on host", newRequest(t, http.MethodGet, "https://other.example.com/api/v1/items/1", ""), http.StatusOK, map[string]any{"id": float64(1), "name": "carol"}},
+		{"fetch under prefix is not affected", newRequest(t, http.MethodGet, "https://example.com/svc/api/v1/items/1", ""), http.StatusOK, map[string]any{"id": float64(1), "name": "bob"}},
+		{"delete", newRequest(t, http.MethodDelete, "https://example.com/svc/api/v1/items/1", ""), http.StatusNoContent, nil},
+		{"fetch deleted", newRequest(t, http.MethodGet, "https://example.com/svc/api/v1/items/1", ""), http.StatusNotFound, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tc.Do(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				res.Body.Close()
+			})
+			if got := res.StatusCode; got != tt.wantStatus {
+				t.Errorf("got %v\nwant %v", got, tt.wantStatus)
+			}
+			if tt.want == nil {
+				return
+			}
+			var got map[string]any
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			for k, want := range tt.want {
+				if got[k] != want {
+					t.Errorf("%s: got %v\nwant %v", k, got[k], want)
+				}
+			}
+		})
+	}
+}
+
+func TestResponseCRUDBasePath(t *testing.T) {
+	rt := NewRouter(t, OpenApi3("testdata/openapi3-crud.yml"), BasePath("/base"))
+	rt.ResponseCRUD()
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	res, err := ts.Client().Do(newRequest(t, http.MethodPost, "https://example.com/base/api/v1/items", `{"name":"alice"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if got, want := res.Header.Get("Location"), "/base/api/v1/items/1"; got != want {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+}
+
+func TestResponseCRUDList(t *testing.T) {
+	rt := NewRouter(t, OpenApi3("testdata/openapi3.yml"))
+	rt.ResponseCRUD()
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	tc := ts.Client()
+	for _, body := range []string{`{"username":"alice","password":"passw0rd"}`, `{"username":"bob","password":"passw0rd"}`} {
+		res, err := tc.Do(newRequest(t, http.MethodPost, "https://example.com/api/v1/users", body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+	res, err := tc.Get("https://example.com/api/v1/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		res.Body.Close()
+	})
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]any
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %v\nwant %v", len(got), 2)
+	}
+	if got[0]["username"] != "alice" || got[1]["username"] != "bob" {
+		t.Errorf("got %s", b)
+	}
+}
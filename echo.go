@@ -0,0 +1,316 @@
+package httpstub
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pb33f/libopenapi-validator/paths"
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"go.yaml.in/yaml/v4"
+)
+
+var pathParamRe = regexp.MustCompile(`\{([^}]+)\}`)
+
+// EchoRequest echoes path parameters, query values and request body fields into the top-level properties of the response which have the same names.
+// Values are converted to the type of the generated (or example) value when possible.
+// Precedence is path parameters, query values, then request body fields. Nested objects and arrays in the response are left as they are.
+func EchoRequest() responseExampleOption {
+	return func(c *responseExampleConfig) error {
+		c.echoRequest = true
+		return nil
+	}
+}
+
+// requestValues collects path parameters, query values and top-level request body fields of the request.
+func requestValues(r *http.Request, doc *v3.Document, pathTemplate string) map[string]any {
+	values := map[string]any{}
+	if r.Body != nil {
+		b, err := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		if err == nil {
+			var body map[string]any
+			if err := json.Unmarshal(b, &body); err == nil {
+				for k, v := range body {
+					values[k] = v
+				}
+			}
+		}
+	}
+	for k, v := range r.URL.Query() {
+		if len(v) > 0 {
+			values[k] = v[0]
+		}
+	}
+	for k, v := range pathParams(paths.StripRequestPath(r, doc), pathTemplate) {
+		values[k] = v
+	}
+	return values
+}
+
+// pathParams extracts path parameters from path using templated path such as /users/{id}.
+func pathParams(path, pathTemplate string) map[string]string {
+	var names []string
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	last := 0
+	for _, loc := range pathParamRe.FindAllStringSubmatchIndex(pathTemplate, -1) {
+		pattern.WriteString(regexp.QuoteMeta(pathTemplate[last:loc[0]]))
+		pattern.WriteString("([^/]+)")
+		names = append(names, pathTemplate[loc[2]:loc[3]])
+		last = loc[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(pathTemplate[last:]))
+	pattern.WriteString("/?$")
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return nil
+	}
+	matches := re.FindStringSubmatch(path)
+	if matches == nil {
+		return nil
+	}
+	params := map[string]string{}
+	for i, name := range names {
+		v, err := url.PathUnescape(matches[i+1])
+		if err != nil {
+			v = matches[i+1]
+		}
+		params[name] = v
+	}
+	return params
+}
+
+// echoValues replaces values of top-level properties of mapping node which have the same names as values.
+// Properties declared in schema but missing in node are added. Nested properties are not replaced
+// since the request values are not for them (e.g. id of the request is not owner.id of the response).
+// writeOnly properties are not echoed since responses must not contain them (e.g. password).
+func echoValues(node *yaml.Node, schema *base.Schema, values map[string]any) {
+	if node.Kind == yaml.DocumentNode {
+		for _, n := range node.Content {
+			echoValues(n, schema, values)
+		}
+		return
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	exists := map[string]struct{}{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		k, v := node.Content[i], node.Content[i+1]
+		exists[k.Value] = struct{}{}
+		prop := schemaProperty(schema, k.Value)
+		value, ok := values[k.Value]
+		if !ok || isWriteOnlyProperty(schema, k.Value) {
+			continue
+		}
+		if n, ok := echoNode(echoKind(v, prop), value); ok {
+			node.Content[i+1] = n
+		}
+	}
+	for _, name := range schemaPropertyNames(schema) {
+		if _, ok := exists[name]; ok {
+			continue
+		}
+		value, ok := values[name]
+		if !ok || isWriteOnlyProperty(schema, name) {
+			continue
+		}
+		n, ok := echoNode(echoKind(nil, schemaProperty(schema, name)), value)
+		if !ok {
+			continue
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}, n)
+	}
+}
+
+// echoKind returns the JSON type to echo using the type of schema, or the type of node if schema has no type.
+func echoKind(node *yaml.Node, schema *base.Schema) string {
+	if schema != nil {
+		for _, t := range schema.Type {
+			if t != "null" {
+				return t
+			}
+		}
+	}
+	if node == nil {
+		return ""
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	case yaml.ScalarNode:
+		switch node.Tag {
+		case "!!int":
+			return "integer"
+		case "!!float":
+			return "number"
+		case "!!bool":
+			return "boolean"
+		case "!!str":
+			return "string"
+		}
+	}
+	return ""
+}
+
+// echoNode converts value to the node of kind (JSON type).
+func echoNode(kind string, value any) (*yaml.Node, bool) {
+	switch kind {
+	case "object", "array":
+		switch value.(type) {
+		case map[string]any:
+			if kind != "object" {
+				return nil, false
+			}
+		case []any:
+			if kind != "array" {
+				return nil, false
+			}
+		default:
+			return nil, false
+		}
+		n := &yaml.Node{}
+		if err := n.Encode(value); err != nil {
+			return nil, false
+		}
+		return n, true
+	}
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		s = strconv.FormatBool(v)
+	default:
+		return nil, false
+	}
+	switch kind {
+	case "integer":
+		if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+			return nil, false
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: s}, true
+	case "number":
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return nil, false
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: s}, true
+	case "boolean":
+		s = strings.ToLower(s)
+		if s != "true" && s != "false" {
+			return nil, false
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: s}, true
+	case "string":
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s, Style: yaml.DoubleQuotedStyle}, true
+	}
+	return nil, false
+}
+
+// responseSchema returns the schema of the response content.
+func responseSchema(responses *v3.Responses, status int, contentType string) *base.Schema {
+	sp := responseSchemaProxy(responses, status, contentType)
+	if sp == nil {
+		return nil
+	}
+	return sp.Schema()
+}
+
+// responseSchemaProxy returns the schema proxy of the response content.
+func responseSchemaProxy(responses *v3.Responses, status int, contentType string) *base.SchemaProxy {
+	res := findResponse(responses, status)
+	if res == nil || res.Content == nil {
+		return nil
+	}
+	mt, ok := res.Content.Get(contentType)
+	if !ok || mt == nil {
+		return nil
+	}
+	return mt.Schema
+}
+
+// findResponse returns the response of status, falling back from the exact status code to the range (e.g. 2XX) and then default.
+func findResponse(responses *v3.Responses, status int) *v3.Response {
+	if responses == nil {
+		return nil
+	}
+	code := strconv.Itoa(status)
+	if responses.Codes != nil {
+		for _, key := range []string{code, code[:1] + "XX", code[:1] + "xx"} {
+			if res, ok := responses.Codes.Get(key); ok && res != nil {
+				return res
+			}
+		}
+	}
+	return responses.Default
+}
+
+// schemaProperty returns the schema of the property (including properties of allOf).
+func schemaProperty(schema *base.Schema, name string) *base.Schema {
+	if schema == nil {
+		return nil
+	}
+	if schema.Properties != nil {
+		if p, ok := schema.Properties.Get(name); ok && p != nil {
+			return p.Schema()
+		}
+	}
+	for _, sp := range schema.AllOf {
+		if p := schemaProperty(sp.Schema(), name); p != nil {
+			return p
+		}
+	}
+	return nil
+}
+
+// schemaPropertyNames returns the names of the properties (including properties of allOf) in declared order.
+func schemaPropertyNames(schema *base.Schema) []string {
+	if schema == nil {
+		return nil
+	}
+	var names []string
+	if schema.Properties != nil {
+		for name := range schema.Properties.KeysFromOldest() {
+			names = append(names, name)
+		}
+	}
+	for _, sp := range schema.AllOf {
+		names = append(names, schemaPropertyNames(sp.Schema())...)
+	}
+	return names
+}
+
+// isWriteOnlyProperty reports whether the property of the schema is writeOnly, which responses must not contain.
+func isWriteOnlyProperty(schema *base.Schema, name string) bool {
+	p := schemaProperty(schema, name)
+	return p != nil && p.WriteOnly != nil && *p.WriteOnly
+}
+
+// cloneYAMLNode returns a deep copy of node.
+func cloneYAMLNode(node *yaml.Node) *yaml.Node {
+	if node == nil {
+		return nil
+	}
+	n := *node
+	if node.Content != nil {
+		n.Content = make([]*yaml.Node, len(node.Content))
+		for i, c := range node.Content {
+			n.Content[i] = cloneYAMLNode(c)
+		}
+	}
+	if node.Alias != nil {
+		n.Alias = cloneYAMLNode(node.Alias)
+	}
+	return &n
+}
@@ -0,0 +1,216 @@
+package httpstub
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestEchoRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		mode ResponseMode
+		req  *http.Request
+		want map[string]any
+	}{
+		{
+			"path parameter and query",
+			AlwaysGenerate,
+			newRequest(t, http.MethodGet, "https://example.com/api/v1/items/42?verbose=true", ""),
+			map[string]any{"id": float64(42), "verbose": true},
+		},
+		{
+			"request body",
+			AlwaysGenerate,
+			newRequest(t, http.MethodPost, "https://example.com/api/v1/items", `{"name":"alice","owner":{"name":"bob"}}`),
+			map[string]any{"name": "alice", "owner": map[string]any{"name": "bob"}},
+		},
+		{
+			"example",
+			ExamplesOnly,
+			newRequest(t, http.MethodGet, "https://example.com/api/v1/items/42", ""),
+			map[string]any{"id": float64(42), "name": "example", "verbose": false, "tags": []any{map[string]any{"id": float64(100)}}},
+		},
+		{
+			"nested properties are not echoed",
+			ExamplesOnly,
+			newRequest(t, http.MethodGet, "https://example.com/api/v1/items/42?name=changed", ""),
+			map[string]any{"id": float64(42), "name": "changed", "owner": map[string]any{"name": "carol"}, "tags": []any{map[string]any{"id": float64(100)}}},
+		},
+		{
+			"value of different type is not echoed",
+			ExamplesOnly,
+			newRequest(t, http.MethodGet, "https://example.com/api/v1/items/42?verbose=yes", ""),
+			map[string]any{"id": float64(42), "verbose": false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := NewRouter(t, OpenApi3("testdata/openapi3-echo.yml"), DynamicResponseMode(tt.mode), SkipValidateRequest(true))
+			rt.Method(tt.req.Method).Path("/api/v1/items*").ResponseDynamic(EchoRequest())
+			ts := rt.Server()
+			t.Cleanup(func() {
+				ts.Close()
+			})
+			for range 2 {
+				res, err := ts.Client().Do(cloneReq(tt.req))
+				if err != nil {
+					t.Fatal(err)
+				}
+				b, err := io.ReadAll(res.Body)
+				res.Body.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got map[string]any
+				if err := json.Unmarshal(b, &got); err != nil {
+					t.Fatal(err)
+				}
+				for k, want := range tt.want {
+					gotb, _ := json.Marshal(got[k])
+					wantb, _ := json.Marshal(want)
+					if string(gotb) != string(wantb) {
+						t.Errorf("%s: got %s\nwant %s", k, gotb, wantb)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEchoRequestDoesNotModifyExample(t *testing.T) {
+	rt := NewRouter(t, OpenApi3("testdata/openapi3-echo.yml"), DynamicResponseMode(ExamplesOnly))
+	rt.Method(http.MethodGet).Path("/api/v1/items/1").ResponseDynamic()
+	rt.Method(http.MethodGet).Path("/api/v1/items/*").ResponseDynamic(EchoRequest())
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	for _, path := range []string{"/api/v1/items/42", "/api/v1/items/1"} {
+		res, err := ts.Client().Get("https://example.com" + path + "?name=changed")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+	res, err := ts.Client().Get("https://example.com/api/v1/items/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		res.Body.Close()
+	})
+	var got map[string]any
+	if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got["name"] != "example" {
+		t.Errorf("got %v\nwant %v", got["name"], "example")
+	}
+}
+
+func TestEchoRequestWriteOnly(t *testing.T) {
+	rt := NewRouter(t, OpenApi3("testdata/openapi3-echo.yml"), DynamicResponseMode(ExamplesOnly))
+	rt.Method(http.MethodPost).Path("/api/v1/accounts").ResponseDynamic(EchoRequest())
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	res, err := ts.Client().Post(ts.URL+"/api/v1/accounts", "application/json", strings.NewReader(`{"username":"alice","password":"passw0rd"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		res.Body.Close()
+	})
+	var got map[string]any
+	if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got["username"] != "alice" {
+		t.Errorf("got %v\nwant %v", got["username"], "alice")
+	}
+	// writeOnly properties are not echoed
+	if v, ok := got["password"]; ok {
+		t.Errorf("got %v\nwant no password", v)
+	}
+}
+
+func TestEchoRequestRangeResponse(t *testing.T) {
+	rt := NewRouter(t, OpenApi3("testdata/openapi3-response-ranges.yml"), DynamicResponseMode(ExamplesOnly))
+	rt.Method(http.MethodGet).Path("/api/v1/things/*").ResponseDynamic(Status("2*"), EchoRequest())
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	res, err := ts.Client().Get("https://example.com/api/v1/things/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		res.Body.Close()
+	})
+	if got := res.StatusCode; got != http.StatusOK {
+		t.Errorf("got %v\nwant %v", got, http.StatusOK)
+	}
+	if got := res.Header.Get("X-Thing-Id"); got != "1" {
+		t.Errorf("got %v\nwant %v", got, "1")
+	}
+	var got map[string]any
+	if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got["id"] != float64(42) {
+		t.Errorf("got %v\nwant %v", got["id"], 42)
+	}
+}
+
+func TestFindResponse(t *testing.T) {
+	rt := NewRouter(t, OpenApi3("testdata/openapi3-response-ranges.yml"))
+	op := rt.openAPI3Specs[0].model.Paths.PathItems.GetOrZero("/things/{id}").Get
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{http.StatusOK, "OK"},
+		{http.StatusNoContent, "OK"},
+		{http.StatusNotFound, "Error"},
+	}
+	for _, tt := range tests {
+		res := findResponse(op.Responses, tt.status)
+		if res == nil {
+			t.Fatalf("%d: got nil", tt.status)
+		}
+		if res.Description != tt.want {
+			t.Errorf("%d: got %v\nwant %v", tt.status, res.Description, tt.want)
+		}
+	}
+}
+
+func TestPathParams(t *testing.T) {
+	tests := []struct {
+		path         string
+		pathTemplate string
+		want         map[string]string
+	}{
+		{"/users/42", "/users/{id}", map[string]string{"id": "42"}},
+		{"/users/42/posts/a%20b", "/users/{id}/posts/{postId}", map[string]string{"id": "42", "postId": "a b"}},
+		{"/files/report.json", "/files/{name}.json", map[string]string{"name": "report"}},
+		{"/users", "/users/{id}", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got := pathParams(tt.path, tt.pathTemplate)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v\nwant %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("got %v\nwant %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
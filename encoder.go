@@ -0,0 +1,317 @@
+package httpstub
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	openapijson "github.com/pb33f/libopenapi/json"
+	"go.yaml.in/yaml/v4"
+)
+
+// encodeBody encodes the node as the body of the media type.
+// schema is used for XML (the xml object of the schema) and CSV (the order of columns).
+func encodeBody(node *yaml.Node, schema *base.SchemaProxy, contentType string) ([]byte, error) {
+	if node == nil {
+		return nil, nil
+	}
+	node = unwrapDocumentNode(node)
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	var s *base.Schema
+	if schema != nil {
+		s = schema.Schema()
+	}
+	switch {
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return encodeXML(node, s, xmlRootName(schema))
+	case mediaType == "application/x-www-form-urlencoded":
+		return encodeForm(node)
+	case mediaType == "text/csv":
+		return encodeCSV(node, s)
+	case mediaType == "application/yaml" || mediaType == "application/x-yaml" || mediaType == "text/yaml":
+		return yaml.Marshal(node)
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return openapijson.YAMLNodeToJSON(node, "  ")
+	case strings.HasPrefix(mediaType, "text/") || isBinaryMediaType(mediaType, s):
+		if node.Kind == yaml.ScalarNode {
+			return []byte(node.Value), nil
+		}
+	}
+	return openapijson.YAMLNodeToJSON(node, "  ")
+}
+
+// isBinaryMediaType reports whether the body of the media type is binary.
+func isBinaryMediaType(mediaType string, schema *base.Schema) bool {
+	if schema != nil && (schema.Format == "binary" || schema.Format == "byte") {
+		return true
+	}
+	switch {
+	case mediaType == "application/octet-stream", mediaType == "application/pdf", mediaType == "application/zip":
+		return true
+	case strings.HasPrefix(mediaType, "image/"), strings.HasPrefix(mediaType, "audio/"), strings.HasPrefix(mediaType, "video/"):
+		return true
+	}
+	return false
+}
+
+func unwrapDocumentNode(node *yaml.Node) *yaml.Node {
+	if node.Kind == yaml.DocumentNode && len(node.Content) == 1 {
+		return node.Content[0]
+	}
+	return node
+}
+
+// encodeForm encodes the mapping node as application/x-www-form-urlencoded.
+// Arrays are encoded as repeated keys and objects are encoded as JSON.
+func encodeForm(node *yaml.Node) ([]byte, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("application/x-www-form-urlencoded body must be an object: %s", node.ShortTag())
+	}
+	var pairs []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		k, v := node.Content[i].Value, node.Content[i+1]
+		values := []*yaml.Node{v}
+		if v.Kind == yaml.SequenceNode {
+			values = v.Content
+		}
+		for _, vv := range values {
+			s, err := formValue(vv)
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, url.QueryEscape(k)+"="+url.QueryEscape(s))
+		}
+	}
+	return []byte(strings.Join(pairs, "&")), nil
+}
+
+func formValue(node *yaml.Node) (string, error) {
+	if node.Kind == yaml.ScalarNode {
+		if node.ShortTag() == "!!null" {
+			return "", nil
+		}
+		return node.Value, nil
+	}
+	b, err := openapijson.YAMLNodeToJSON(node, "")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// encodeCSV encodes the node as text/csv with a header row.
+// Columns are ordered by the properties of the item schema, then by the keys of the items.
+func encodeCSV(node *yaml.Node, schema *base.Schema) ([]byte, error) {
+	var rows []*yaml.Node
+	switch node.Kind {
+	case yaml.SequenceNode:
+		rows = node.Content
+		if schema != nil && schema.Items != nil && schema.Items.IsA() {
+			schema = schema.Items.A.Schema()
+		} else {
+			schema = nil
+		}
+	case yaml.MappingNode:
+		rows = []*yaml.Node{node}
+	default:
+		return []byte(node.Value + "\n"), nil
+	}
+	columns := schemaPropertyNames(schema)
+	seen := map[string]struct{}{}
+	for _, c := range columns {
+		seen[c] = struct{}{}
+	}
+	for _, row := range rows {
+		if row.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 0; i < len(row.Content); i += 2 {
+			if _, ok := seen[row.Content[i].Value]; !ok {
+				seen[row.Content[i].Value] = struct{}{}
+				columns = append(columns, row.Content[i].Value)
+			}
+		}
+	}
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+	if len(columns) > 0 {
+		if err := w.Write(columns); err != nil {
+			return nil, err
+		}
+	}
+	for _, row := range rows {
+		var record []string
+		if row.Kind != yaml.MappingNode {
+			v, err := formValue(row)
+			if err != nil {
+				return nil, err
+			}
+			record = []string{v}
+		} else {
+			values := map[string]string{}
+			for i := 0; i+1 < len(row.Content); i += 2 {
+				v, err := formValue(row.Content[i+1])
+				if err != nil {
+					return nil, err
+				}
+				values[row.Content[i].Value] = v
+			}
+			for _, c := range columns {
+				record = append(record, values[c])
+			}
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// xmlRootName returns the name of the root element: the xml name of the schema, the name of the referenced component schema or "root".
+func xmlRootName(schema *base.SchemaProxy) string {
+	if schema == nil {
+		return "root"
+	}
+	if s := schema.Schema(); s != nil && s.XML != nil && s.XML.Name != "" {
+		return s.XML.Name
+	}
+	if ref := schema.GetReference(); ref != "" {
+		return ref[strings.LastIndex(ref, "/")+1:]
+	}
+	return "root"
+}
+
+// encodeXML encodes the node as XML using the xml object of the schema (name, namespace, prefix, attribute and wrapped).
+func encodeXML(node *yaml.Node, schema *base.Schema, rootName string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(buf)
+	enc.Indent("", "  ")
+	if node.Kind == yaml.SequenceNode {
+		// root array is always wrapped
+		if err := encodeXMLArray(enc, rootName, node, schema, true); err != nil {
+			return nil, err
+		}
+	} else if err := encodeXMLElement(enc, rootName, node, schema); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func xmlStartElement(name string, schema *base.Schema) xml.StartElement {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if schema == nil || schema.XML == nil {
+		return start
+	}
+	if schema.XML.Prefix != "" {
+		start.Name.Local = schema.XML.Prefix + ":" + name
+		if schema.XML.Namespace != "" {
+			start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns:" + schema.XML.Prefix}, Value: schema.XML.Namespace})
+		}
+	} else if schema.XML.Namespace != "" {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: schema.XML.Namespace})
+	}
+	return start
+}
+
+func xmlName(name string, schema *base.Schema) string {
+	if schema != nil && schema.XML != nil && schema.XML.Name != "" {
+		return schema.XML.Name
+	}
+	return name
+}
+
+func encodeXMLElement(enc *xml.Encoder, name string, node *yaml.Node, schema *base.Schema) error {
+	start := xmlStartElement(name, schema)
+	switch node.Kind {
+	case yaml.MappingNode:
+		type child struct {
+			name   string
+			node   *yaml.Node
+			schema *base.Schema
+		}
+		var children []child
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, v := node.Content[i].Value, node.Content[i+1]
+			prop := schemaProperty(schema, k)
+			if prop != nil && prop.XML != nil && prop.XML.Attribute && v.Kind == yaml.ScalarNode {
+				attr := xmlName(k, prop)
+				if prop.XML.Prefix != "" {
+					attr = prop.XML.Prefix + ":" + attr
+				}
+				start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: attr}, Value: v.Value})
+				continue
+			}
+			children = append(children, child{name: k, node: v, schema: prop})
+		}
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, c := range children {
+			if c.node.Kind == yaml.SequenceNode {
+				wrapped := c.schema != nil && c.schema.XML != nil && c.schema.XML.Wrapped
+				if err := encodeXMLArray(enc, xmlName(c.name, c.schema), c.node, c.schema, wrapped); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := encodeXMLElement(enc, xmlName(c.name, c.schema), c.node, c.schema); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		return encodeXMLArray(enc, name, node, schema, true)
+	default:
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		if node.ShortTag() != "!!null" {
+			if err := enc.EncodeToken(xml.CharData(node.Value)); err != nil {
+				return err
+			}
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// encodeXMLArray encodes the sequence node. Items are named by the xml name of the items schema, or name (the name of the array) by default.
+func encodeXMLArray(enc *xml.Encoder, name string, node *yaml.Node, schema *base.Schema, wrapped bool) error {
+	var items *base.Schema
+	if schema != nil && schema.Items != nil && schema.Items.IsA() {
+		items = schema.Items.A.Schema()
+	}
+	itemName := xmlName(name, items)
+	if !wrapped {
+		for _, n := range node.Content {
+			if err := encodeXMLElement(enc, itemName, n, items); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	start := xmlStartElement(name, schema)
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, n := range node.Content {
+		if err := encodeXMLElement(enc, itemName, n, items); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
@@ -0,0 +1,109 @@
+package httpstub
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestResponseDynamicMediaTypes(t *testing.T) {
+	rt := NewRouter(t, OpenApi3("testdata/openapi3-media-types.yml"), DynamicResponseMode(ExamplesOnly))
+	rt.ResponseDynamic()
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	tests := []struct {
+		method          string
+		path            string
+		wantContentType string
+		want            string
+	}{
+		{
+			http.MethodGet, "/pets/1", "application/xml",
+			`<?xml version="1.0" encoding="UTF-8"?>
+<Pet id="1">
+  <p:petName xmlns:p="https://example.com/schema">pochi</p:petName>
+  <tags>
+    <tag>dog</tag>
+    <tag>small</tag>
+  </tags>
+  <photoUrl>https://example.com/1.png</photoUrl>
+</Pet>`,
+		},
+		{
+			http.MethodGet, "/shelf", "application/xml",
+			`<?xml version="1.0" encoding="UTF-8"?>
+<Shelf>
+  <books>
+    <books>alpha</books>
+    <books>beta</books>
+  </books>
+</Shelf>`,
+		},
+		{
+			http.MethodGet, "/pets", "text/csv",
+			"id,name,tags,photoUrls\n1,pochi,,\n2,\"tama, jr.\",,\n",
+		},
+		{
+			http.MethodPost, "/token", "application/x-www-form-urlencoded",
+			"access_token=abc&scope=read&scope=write",
+		},
+		{
+			http.MethodGet, "/version", "text/plain",
+			"v1.0.0",
+		},
+		{
+			http.MethodGet, "/download", "application/octet-stream",
+			"binary-content",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				res.Body.Close()
+			})
+			if got := res.Header.Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("got %v\nwant %v", got, tt.wantContentType)
+			}
+			b, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(b); got != tt.want {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseDynamicGenerateXML(t *testing.T) {
+	rt := NewRouter(t, OpenApi3("testdata/openapi3-media-types.yml"))
+	rt.ResponseDynamic()
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	res, err := ts.Client().Get(ts.URL + "/pets/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		res.Body.Close()
+	})
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); len(got) < 6 || got[:6] != "<?xml " {
+		t.Errorf("got %v\nwant XML", got)
+	}
+}
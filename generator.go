@@ -0,0 +1,589 @@
+package httpstub
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	mrand "math/rand/v2"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	"github.com/pb33f/libopenapi/renderer"
+)
+
+// Generator generates a value of the schema for dynamic responses.
+// rnd is seeded by Seed, so that generated values are deterministic.
+type Generator func(schema *base.Schema, rnd *mrand.Rand) (any, error)
+
+// FormatGenerator registers the generator for schemas of the format (e.g. "date-time" or a custom format such as "order-id").
+// It takes precedence over examples and the builtin generation of the format.
+// Registering it enables UseConstraintGenerator.
+func FormatGenerator(format string, g Generator) Option {
+	return func(c *config) error {
+		if format == "" {
+			return errors.New("empty format of generator")
+		}
+		if c.formatGenerators == nil {
+			c.formatGenerators = map[string]Generator{}
+		}
+		c.formatGenerators[format] = g
+		return nil
+	}
+}
+
+// SchemaGenerator registers the generator for the component schema of the name (e.g. "User" of #/components/schemas/User).
+// It takes precedence over FormatGenerator.
+// Registering it enables UseConstraintGenerator.
+func SchemaGenerator(name string, g Generator) Option {
+	return func(c *config) error {
+		if name == "" {
+			return errors.New("empty schema name of generator")
+		}
+		if c.schemaGenerators == nil {
+			c.schemaGenerators = map[string]Generator{}
+		}
+		c.schemaGenerators[name] = g
+		return nil
+	}
+}
+
+// UseConstraintGenerator sets whether to generate data of dynamic responses honoring formats and constraints of the schema
+// instead of using the mock generator of libopenapi (renderer.MockGenerator).
+func UseConstraintGenerator(use bool) Option {
+	return func(c *config) error {
+		c.useConstraintGenerator = use
+		return nil
+	}
+}
+
+const (
+	// maxGenerateDepth limits the depth of nested schemas to generate
+	maxGenerateDepth = 32
+	// maxUniqueRetries limits the number of retries to generate unique items
+	maxUniqueRetries = 10
+	// maxPatternLength limits the length of repeats (*, +) in patterns
+	maxPatternLength = 10
+)
+
+// generatorBaseTime is the base time of generated date-time, date and time values.
+var generatorBaseTime = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// generator generates values from schemas honoring formats and constraints.
+// Values are generated for responses: writeOnly properties are omitted and readOnly properties are included.
+type generator struct {
+	rng     *mrand.Rand
+	formats map[string]Generator
+	schemas map[string]Generator
+	// mock is the mock generator of libopenapi used instead unless UseConstraintGenerator is set
+	mock *renderer.MockGenerator
+	mu   sync.Mutex
+}
+
+func newGenerator(seed int64, formats, schemas map[string]Generator) *generator {
+	return &generator{
+		rng:     mrand.New(mrand.NewPCG(uint64(seed), uint64(seed)>>32)), //nolint:gosec
+		formats: formats,
+		schemas: schemas,
+	}
+}
+
+// useMock sets the generator to generate values using the mock generator of libopenapi seeded by seed.
+func (g *generator) useMock(seed int64) {
+	mg := renderer.NewMockGenerator(renderer.JSON)
+	mg.SetSeed(seed)
+	g.mock = mg
+}
+
+// generateJSON generates a value of the schema and encodes it as JSON.
+func (g *generator) generateJSON(schema *base.Schema) ([]byte, error) {
+	if g.mock != nil {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.mock.GenerateMock(schema, "")
+	}
+	v, err := g.generate(schema)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// generate generates a value of the schema.
+func (g *generator) generate(schema *base.Schema) (any, error) {
+	if schema == nil {
+		return nil, errors.New("no schema to generate value")
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	v, _, err := g.value(schema, map[string]bool{}, 0)
+	return v, err
+}
+
+// value generates a value of the schema. It returns false if the value cannot be generated (e.g. circular references).
+func (g *generator) value(s *base.Schema, visited map[string]bool, depth int) (any, bool, error) {
+	if s == nil {
+		return nil, true, nil
+	}
+	if depth > maxGenerateDepth {
+		return nil, false, nil
+	}
+	if ref := schemaRef(s); ref != "" {
+		if visited[ref] {
+			return nil, false, nil
+		}
+		visited[ref] = true
+		defer delete(visited, ref)
+		if fn, ok := g.schemas[ref[strings.LastIndex(ref, "/")+1:]]; ok {
+			v, err := fn(s, g.rng)
+			return v, true, err
+		}
+	}
+	if fn, ok := g.formats[s.Format]; ok && s.Format != "" {
+		v, err := fn(s, g.rng)
+		return v, true, err
+	}
+	if s.Const != nil {
+		v, err := decodeYAMLNode(s.Const)
+		return v, true, err
+	}
+	if s.Example != nil {
+		v, err := decodeYAMLNode(s.Example)
+		return v, true, err
+	}
+	if len(s.Examples) > 0 && s.Examples[0] != nil {
+		v, err := decodeYAMLNode(s.Examples[0])
+		return v, true, err
+	}
+	if len(s.Enum) > 0 {
+		v, err := decodeYAMLNode(s.Enum[g.rng.IntN(len(s.Enum))])
+		return v, true, err
+	}
+	switch generateType(s) {
+	case "object":
+		return g.object(s, visited, depth)
+	case "array":
+		return g.array(s, visited, depth)
+	case "string":
+		v, err := g.string(s)
+		return v, true, err
+	case "integer":
+		v, err := g.integer(s)
+		return v, true, err
+	case "number":
+		v, err := g.number(s)
+		return v, true, err
+	case "boolean":
+		return g.rng.IntN(2) == 0, true, nil
+	}
+	return nil, true, nil
+}
+
+// schemaRef returns the reference of the schema (e.g. #/components/schemas/User).
+func schemaRef(s *base.Schema) string {
+	if s.ParentProxy == nil || !s.ParentProxy.IsReference() {
+		return ""
+	}
+	return s.ParentProxy.GetReference()
+}
+
+// generateType returns the type to generate. The type is inferred from the keywords if not declared.
+func generateType(s *base.Schema) string {
+	for _, t := range s.Type {
+		if t != "null" {
+			return t
+		}
+	}
+	switch {
+	case s.Properties != nil || s.AllOf != nil || s.OneOf != nil || s.AnyOf != nil:
+		return "object"
+	case s.Items != nil:
+		return "array"
+	case s.Format != "" || s.Pattern != "":
+		return "string"
+	}
+	return ""
+}
+
+func (g *generator) object(s *base.Schema, visited map[string]bool, depth int) (any, bool, error) {
+	obj := map[string]any{}
+	if s.Properties != nil {
+		// render only required properties if declared, otherwise all properties
+		for name, p := range s.Properties.FromOldest() {
+			required := slices.Contains(s.Required, name)
+			if len(s.Required) > 0 && !required {
+				continue
+			}
+			ps := p.Schema()
+			if ps == nil {
+				continue
+			}
+			if ps.WriteOnly != nil && *ps.WriteOnly {
+				continue
+			}
+			v, ok, err := g.value(ps, visited, depth+1)
+			if err != nil {
+				return nil, false, fmt.Errorf("%s: %w", name, err)
+			}
+			if !ok {
+				if required {
+					return nil, false, nil
+				}
+				continue
+			}
+			obj[name] = v
+		}
+	}
+	for _, sp := range s.AllOf {
+		v, ok, err := g.value(sp.Schema(), visited, depth+1)
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			return nil, false, nil
+		}
+		m, isObj := v.(map[string]any)
+		if !isObj {
+			return v, true, nil
+		}
+		for k, vv := range m {
+			obj[k] = vv
+		}
+	}
+	for _, of := range [][]*base.SchemaProxy{s.OneOf, s.AnyOf} {
+		if len(of) == 0 {
+			continue
+		}
+		generated := false
+		for _, sp := range of {
+			v, ok, err := g.value(sp.Schema(), visited, depth+1)
+			if err != nil {
+				return nil, false, err
+			}
+			if !ok {
+				continue
+			}
+			m, isObj := v.(map[string]any)
+			if !isObj {
+				return v, true, nil
+			}
+			for k, vv := range m {
+				obj[k] = vv
+			}
+			generated = true
+			break
+		}
+		if !generated {
+			return nil, false, nil
+		}
+	}
+	return obj, true, nil
+}
+
+func (g *generator) array(s *base.Schema, visited map[string]bool, depth int) (any, bool, error) {
+	items := []any{}
+	if s.Items == nil || !s.Items.IsA() || s.Items.A == nil {
+		return items, true, nil
+	}
+	is := s.Items.A.Schema()
+	if is == nil {
+		return items, true, nil
+	}
+	n := int64(1)
+	if s.MinItems != nil {
+		n = *s.MinItems
+	}
+	if s.MaxItems != nil && n > *s.MaxItems {
+		n = *s.MaxItems
+	}
+	unique := s.UniqueItems != nil && *s.UniqueItems
+	seen := map[string]bool{}
+	for i := int64(0); i < n; i++ {
+		var v any
+		for retry := 0; ; retry++ {
+			vv, ok, err := g.value(is, visited, depth+1)
+			if err != nil {
+				return nil, false, err
+			}
+			if !ok {
+				return items, true, nil
+			}
+			v = vv
+			if !unique {
+				break
+			}
+			b, _ := json.Marshal(v)
+			if !seen[string(b)] || retry >= maxUniqueRetries {
+				seen[string(b)] = true
+				break
+			}
+		}
+		items = append(items, v)
+	}
+	return items, true, nil
+}
+
+func (g *generator) string(s *base.Schema) (string, error) {
+	switch s.Format {
+	case "date-time":
+		return g.time().Format(time.RFC3339), nil
+	case "date":
+		return g.time().Format(time.DateOnly), nil
+	case "time":
+		return g.time().Format(time.TimeOnly), nil
+	case "email":
+		return fmt.Sprintf("%s@%s.example.com", g.word(3, 10), g.word(3, 10)), nil
+	case "hostname":
+		return fmt.Sprintf("%s.example.com", g.word(3, 10)), nil
+	case "ipv4":
+		return fmt.Sprintf("%d.%d.%d.%d", g.rng.IntN(223)+1, g.rng.IntN(256), g.rng.IntN(256), g.rng.IntN(254)+1), nil
+	case "ipv6":
+		parts := make([]string, 8)
+		for i := range parts {
+			parts[i] = fmt.Sprintf("%x", g.rng.IntN(0x10000))
+		}
+		return strings.Join(parts, ":"), nil
+	case "uri", "url":
+		return fmt.Sprintf("https://%s.example.com/%s", g.word(3, 10), g.word(3, 10)), nil
+	case "uri-reference":
+		return fmt.Sprintf("/%s/%s", g.word(3, 10), g.word(3, 10)), nil
+	case "uuid":
+		return g.uuid(), nil
+	case "byte":
+		return base64.StdEncoding.EncodeToString([]byte(g.word(3, 10))), nil
+	}
+	if s.Pattern != "" {
+		return g.pattern(s)
+	}
+	minLength, maxLength := int64(3), int64(10)
+	if s.MinLength != nil {
+		minLength = *s.MinLength
+		if maxLength < minLength {
+			maxLength = minLength
+		}
+	}
+	if s.MaxLength != nil {
+		maxLength = *s.MaxLength
+		if minLength > maxLength {
+			minLength = maxLength
+		}
+	}
+	return g.word(minLength, maxLength), nil
+}
+
+// pattern generates a string matching the pattern and the length constraints.
+func (g *generator) pattern(s *base.Schema) (string, error) {
+	re, err := regexp.Compile(s.Pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern %q: %w", s.Pattern, err)
+	}
+	syn, err := syntax.Parse(s.Pattern, syntax.Perl)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern %q: %w", s.Pattern, err)
+	}
+	limit := maxPatternLength
+	if s.MinLength != nil && int(*s.MinLength) > limit {
+		limit = int(*s.MinLength)
+	}
+	if s.MaxLength != nil && int(*s.MaxLength) < limit {
+		limit = int(*s.MaxLength)
+	}
+	for i := range maxUniqueRetries {
+		// later retries repeat longer to satisfy minLength
+		var sb strings.Builder
+		if err := g.regexp(&sb, syn, limit*i/(maxUniqueRetries-1), limit); err != nil {
+			return "", fmt.Errorf("invalid pattern %q: %w", s.Pattern, err)
+		}
+		v := sb.String()
+		n := int64(len([]rune(v)))
+		if re.MatchString(v) && (s.MinLength == nil || n >= *s.MinLength) && (s.MaxLength == nil || n <= *s.MaxLength) {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("failed to generate a string matching pattern %q and the length constraints", s.Pattern)
+}
+
+// regexp writes a string matching the parsed regular expression to sb.
+// Unbounded repeats (*, +, {n,}) are repeated between atLeast and limit times.
+func (g *generator) regexp(sb *strings.Builder, re *syntax.Regexp, atLeast, limit int) error {
+	switch re.Op {
+	case syntax.OpNoMatch:
+		return errors.New("pattern matches nothing")
+	case syntax.OpLiteral:
+		sb.WriteString(string(re.Rune))
+	case syntax.OpCharClass:
+		sb.WriteRune(g.charClass(re.Rune))
+	case syntax.OpAnyCharNotNL, syntax.OpAnyChar:
+		sb.WriteByte(byte('a' + g.rng.IntN(26)))
+	case syntax.OpCapture:
+		return g.regexp(sb, re.Sub[0], atLeast, limit)
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if err := g.regexp(sb, sub, atLeast, limit); err != nil {
+				return err
+			}
+		}
+	case syntax.OpAlternate:
+		return g.regexp(sb, re.Sub[g.rng.IntN(len(re.Sub))], atLeast, limit)
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		lo, hi := re.Min, re.Max
+		switch re.Op {
+		case syntax.OpStar:
+			lo, hi = 0, -1
+		case syntax.OpPlus:
+			lo, hi = 1, -1
+		case syntax.OpQuest:
+			lo, hi = 0, 1
+		}
+		if hi < 0 {
+			lo, hi = max(lo, atLeast), max(lo, limit)
+		}
+		for range lo + g.rng.IntN(hi-lo+1) {
+			if err := g.regexp(sb, re.Sub[0], atLeast, limit); err != nil {
+				return err
+			}
+		}
+	}
+	// empty-width assertions (^, $, \b, ...) write nothing
+	return nil
+}
+
+// charClass returns a rune of the character class given as pairs of ranges.
+// Printable ASCII runes are preferred so that negated classes (e.g. [^,]) do not generate control or exotic characters.
+func (g *generator) charClass(ranges []rune) rune {
+	var printable []rune
+	for i := 0; i+1 < len(ranges); i += 2 {
+		lo, hi := max(ranges[i], '!'), min(ranges[i+1], '~')
+		if lo <= hi {
+			printable = append(printable, lo, hi)
+		}
+	}
+	if len(printable) > 0 {
+		ranges = printable
+	}
+	var total int
+	for i := 0; i+1 < len(ranges); i += 2 {
+		total += int(ranges[i+1]-ranges[i]) + 1
+	}
+	n := g.rng.IntN(total)
+	for i := 0; i+1 < len(ranges); i += 2 {
+		size := int(ranges[i+1]-ranges[i]) + 1
+		if n < size {
+			return ranges[i] + rune(n)
+		}
+		n -= size
+	}
+	return ranges[0]
+}
+
+// integer generates an integer between minimum and maximum (1 to 100 by default) which is a multiple of multipleOf.
+func (g *generator) integer(s *base.Schema) (int64, error) {
+	lo, hi := g.bounds(s, 1)
+	ilo, ihi := int64(math.Ceil(lo)), int64(math.Floor(hi))
+	if s.MultipleOf != nil && *s.MultipleOf >= 1 {
+		m := int64(*s.MultipleOf)
+		klo, khi := ceilDiv(ilo, m), floorDiv(ihi, m)
+		if klo > khi {
+			return 0, fmt.Errorf("no multiple of %d between %d and %d", m, ilo, ihi)
+		}
+		return (klo + g.rng.Int64N(khi-klo+1)) * m, nil
+	}
+	if ilo > ihi {
+		return 0, fmt.Errorf("no integer between %v and %v", lo, hi)
+	}
+	return ilo + g.rng.Int64N(ihi-ilo+1), nil
+}
+
+// number generates a number between minimum and maximum (1 to 100 by default) which is a multiple of multipleOf.
+func (g *generator) number(s *base.Schema) (float64, error) {
+	lo, hi := g.bounds(s, 0.01)
+	if s.MultipleOf != nil && *s.MultipleOf > 0 {
+		m := *s.MultipleOf
+		klo, khi := math.Ceil(lo/m), math.Floor(hi/m)
+		if klo > khi {
+			return 0, fmt.Errorf("no multiple of %v between %v and %v", m, lo, hi)
+		}
+		return (klo + float64(g.rng.Int64N(int64(khi-klo)+1))) * m, nil
+	}
+	// round to 2 decimal places for readability
+	v := math.Round((lo+g.rng.Float64()*(hi-lo))*100) / 100
+	return math.Min(math.Max(v, lo), hi), nil
+}
+
+// bounds returns the inclusive range of the number. step is the difference used for exclusive bounds.
+func (g *generator) bounds(s *base.Schema, step float64) (float64, float64) {
+	lo, hi := 1.0, 100.0
+	hasLo, hasHi := false, false
+	if s.Minimum != nil {
+		lo, hasLo = *s.Minimum, true
+		if s.ExclusiveMinimum != nil && s.ExclusiveMinimum.IsA() && s.ExclusiveMinimum.A {
+			lo += step
+		}
+	}
+	if s.ExclusiveMinimum != nil && s.ExclusiveMinimum.IsB() {
+		lo, hasLo = s.ExclusiveMinimum.B+step, true
+	}
+	if s.Maximum != nil {
+		hi, hasHi = *s.Maximum, true
+		if s.ExclusiveMaximum != nil && s.ExclusiveMaximum.IsA() && s.ExclusiveMaximum.A {
+			hi -= step
+		}
+	}
+	if s.ExclusiveMaximum != nil && s.ExclusiveMaximum.IsB() {
+		hi, hasHi = s.ExclusiveMaximum.B-step, true
+	}
+	switch {
+	case hasLo && !hasHi && hi < lo:
+		hi = lo + 99
+	case hasHi && !hasLo && lo > hi:
+		lo = hi - 99
+	}
+	return lo, hi
+}
+
+// time returns a time within 10 years from 2020-01-01T00:00:00Z in seconds.
+func (g *generator) time() time.Time {
+	return generatorBaseTime.Add(time.Duration(g.rng.Int64N(10*365*24*60*60)) * time.Second)
+}
+
+// uuid returns a UUID version 4.
+func (g *generator) uuid() string {
+	var b [16]byte
+	for i := range b {
+		b[i] = byte(g.rng.IntN(256))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// word returns a word of lowercase letters whose length is between minLength and maxLength.
+func (g *generator) word(minLength, maxLength int64) string {
+	n := minLength
+	if maxLength > minLength {
+		n += g.rng.Int64N(maxLength - minLength + 1)
+	}
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte('a' + g.rng.IntN(26))
+	}
+	return string(b)
+}
+
+func ceilDiv(a, b int64) int64 {
+	return -floorDiv(-a, b)
+}
+
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
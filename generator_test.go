@@ -0,0 +1,220 @@
+package httpstub
+
+import (
+	"encoding/json"
+	"io"
+	mrand "math/rand/v2"
+	"net"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+)
+
+func TestGenerator(t *testing.T) {
+	rt := NewRouter(t, OpenApi3("testdata/openapi3-generator.yml"), FormatGenerator("order-id", func(_ *base.Schema, rnd *mrand.Rand) (any, error) {
+		return "ORD-" + string(rune('A'+rnd.IntN(26))), nil
+	}))
+	rt.ResponseDynamic()
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	tc := ts.Client()
+	for range 10 {
+		res, err := tc.Get("https://example.com/api/v1/accounts/f47ac10b-58cc-4372-a567-0e02b2c3d479")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got map[string]any
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatal(err)
+		}
+		checks := []struct {
+			key string
+			fn  func(v any) bool
+		}{
+			{"id", func(v any) bool {
+				return regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(v.(string))
+			}},
+			{"email", func(v any) bool { return regexp.MustCompile(`^[a-z]+@[a-z.]+$`).MatchString(v.(string)) }},
+			{"homepage", func(v any) bool {
+				u, err := url.Parse(v.(string))
+				return err == nil && u.Scheme == "https" && u.Host != ""
+			}},
+			{"ip", func(v any) bool { return net.ParseIP(v.(string)).To4() != nil }},
+			{"createdAt", func(v any) bool {
+				_, err := time.Parse(time.RFC3339, v.(string))
+				return err == nil
+			}},
+			{"birthday", func(v any) bool {
+				_, err := time.Parse(time.DateOnly, v.(string))
+				return err == nil
+			}},
+			{"code", func(v any) bool { return regexp.MustCompile(`^[A-Z]{3}-[0-9]{4}$`).MatchString(v.(string)) }},
+			{"status", func(v any) bool { return v == "active" || v == "suspended" }},
+			{"level", func(v any) bool { return v == float64(10) || v == float64(15) || v == float64(20) }},
+			{"score", func(v any) bool { return v.(float64) > 0 && v.(float64) <= 1 }},
+			{"tags", func(v any) bool {
+				tags := v.([]any)
+				return len(tags) == 2 && tags[0] != tags[1]
+			}},
+			{"password", func(v any) bool { return v == nil }},
+			{"orderId", func(v any) bool { return regexp.MustCompile(`^ORD-[A-Z]$`).MatchString(v.(string)) }},
+		}
+		for _, c := range checks {
+			if !c.fn(got[c.key]) {
+				t.Errorf("invalid %s: %v", c.key, got[c.key])
+			}
+		}
+	}
+}
+
+func TestSchemaGenerator(t *testing.T) {
+	rt := NewRouter(t, OpenApi3("testdata/openapi3-generator.yml"), SchemaGenerator("Order", func(_ *base.Schema, _ *mrand.Rand) (any, error) {
+		return map[string]any{"id": "order-1"}, nil
+	}))
+	rt.ResponseDynamic()
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	res, err := ts.Client().Get("https://example.com/api/v1/orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		res.Body.Close()
+	})
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[\n  {\n    \"id\": \"order-1\"\n  }\n]"
+	if got := string(b); got != want {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+}
+
+func TestGeneratorDeterministic(t *testing.T) {
+	rt := NewRouter(t, OpenApi3("testdata/openapi3-generator.yml"))
+	v3m := rt.openAPI3Specs[0].model
+	schema := v3m.Components.Schemas.GetOrZero("Account").Schema()
+	var got [][]byte
+	for range 2 {
+		g := newGenerator(12345, nil, nil)
+		b, err := g.generateJSON(schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, b)
+	}
+	if string(got[0]) != string(got[1]) {
+		t.Errorf("got %s\nwant %s", got[1], got[0])
+	}
+}
+
+func TestGeneratorBounds(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name   string
+		schema *base.Schema
+		want   []int64
+	}{
+		{"default", &base.Schema{}, []int64{1, 100}},
+		{"minimum only", &base.Schema{Minimum: f(1000)}, []int64{1000, 1099}},
+		{"maximum only", &base.Schema{Maximum: f(-5)}, []int64{-104, -5}},
+		{"exclusive", &base.Schema{Minimum: f(0), Maximum: f(3), ExclusiveMinimum: &base.DynamicValue[bool, float64]{A: true}, ExclusiveMaximum: &base.DynamicValue[bool, float64]{A: true}}, []int64{1, 2}},
+		{"exclusive 3.1", &base.Schema{ExclusiveMinimum: &base.DynamicValue[bool, float64]{N: 1, B: 5}, ExclusiveMaximum: &base.DynamicValue[bool, float64]{N: 1, B: 7}}, []int64{6, 6}},
+	}
+	g := newGenerator(1, nil, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 20 {
+				got, err := g.integer(tt.schema)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got < tt.want[0] || got > tt.want[1] {
+					t.Errorf("got %v\nwant between %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestGeneratorPattern(t *testing.T) {
+	i := func(v int64) *int64 { return &v }
+	tests := []struct {
+		name    string
+		schema  *base.Schema
+		wantErr bool
+	}{
+		{"pattern", &base.Schema{Pattern: `^[a-f0-9]{8}$`}, false},
+		{"minLength longer than the default limit", &base.Schema{Pattern: `^[a-z]+$`, MinLength: i(20)}, false},
+		{"alternation and optional", &base.Schema{Pattern: `^(foo|bar)-\d{2,4}(\.[A-Z])?$`}, false},
+		{"negated class and unanchored", &base.Schema{Pattern: `[^,]+@\w*`}, false},
+		{"unsatisfiable", &base.Schema{Pattern: `^[a-z]{5}$`, MaxLength: i(3)}, true},
+	}
+	g := newGenerator(1, nil, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := g.pattern(tt.schema)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v\nwantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !regexp.MustCompile(tt.schema.Pattern).MatchString(got) {
+				t.Errorf("got %v\nwant matching %v", got, tt.schema.Pattern)
+			}
+			if tt.schema.MinLength != nil && int64(len(got)) < *tt.schema.MinLength {
+				t.Errorf("got %v\nwant length >= %v", got, *tt.schema.MinLength)
+			}
+		})
+	}
+}
+
+func TestUseConstraintGenerator(t *testing.T) {
+	orderID := func(_ *base.Schema, _ *mrand.Rand) (any, error) {
+		return "ORD", nil
+	}
+	tests := []struct {
+		name         string
+		opts         []Option
+		wantPassword bool
+	}{
+		// the mock generator of libopenapi does not omit writeOnly properties
+		{"default", nil, true},
+		{"UseConstraintGenerator", []Option{UseConstraintGenerator(true)}, false},
+		{"FormatGenerator", []Option{FormatGenerator("order-id", orderID)}, false},
+		{"SchemaGenerator", []Option{SchemaGenerator("Order", orderID)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]Option{OpenApi3("testdata/openapi3-generator.yml")}, tt.opts...)
+			rt := NewRouter(t, opts...)
+			v3m := rt.openAPI3Specs[0].model
+			schema := v3m.Components.Schemas.GetOrZero("Account").Schema()
+			b, err := rt.generator.generateJSON(schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got map[string]any
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := got["password"]; ok != tt.wantPassword {
+				t.Errorf("got %s\nwant password %v", b, tt.wantPassword)
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+package httpstub
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// GraphQL operation types.
+const (
+	GraphQLQuery        = "query"
+	GraphQLMutation     = "mutation"
+	GraphQLSubscription = "subscription"
+)
+
+// GraphQLError is an error of GraphQL response.
+type GraphQLError struct {
+	Message    string            `json:"message"`
+	Locations  []GraphQLLocation `json:"locations,omitempty"`
+	Path       []any             `json:"path,omitempty"`
+	Extensions map[string]any    `json:"extensions,omitempty"`
+}
+
+// GraphQLLocation is a location in GraphQL document.
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type graphQLMatcher struct {
+	*matcher
+}
+
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+	operationType string
+}
+
+// graphQLResponse is a GraphQL response.
+// data is always present since GraphQL distinguishes "data": null (an error raised during execution) from an absent data.
+type graphQLResponse struct {
+	Data   any            `json:"data"`
+	Errors []GraphQLError `json:"errors,omitempty"`
+}
+
+// GraphQL create request matcher for GraphQL endpoint using path.
+// The request is parsed as GraphQL over HTTP (POST with JSON or application/graphql body, or GET with query parameters).
+func (rt *Router) GraphQL(path string) *graphQLMatcher {
+	m := rt.Path(path).Match(func(r *http.Request) bool {
+		_, err := parseGraphQLRequest(r)
+		return err == nil
+	})
+	// GraphQL endpoints are not described by OpenAPI Document
+	m.SkipValidation()
+	return &graphQLMatcher{matcher: m}
+}
+
+// OperationName append matcher using GraphQL operation name.
+func (m *graphQLMatcher) OperationName(name string) *graphQLMatcher {
+	m.Match(func(r *http.Request) bool {
+		gr, err := parseGraphQLRequest(r)
+		return err == nil && gr.OperationName == name
+	})
+	return m
+}
+
+// OperationType append matcher using GraphQL operation type (query, mutation or subscription).
+func (m *graphQLMatcher) OperationType(typ string) *graphQLMatcher {
+	m.Match(func(r *http.Request) bool {
+		gr, err := parseGraphQLRequest(r)
+		return err == nil && gr.operationType == typ
+	})
+	return m
+}
+
+// Variables append matcher using GraphQL variables.
+// The request matches when it contains all of vars (other variables are ignored).
+func (m *graphQLMatcher) Variables(vars map[string]any) *graphQLMatcher {
+	want, err := normalizeJSON(vars)
+	if err != nil {
+		m.router.t.Fatalf("failed to convert variables: %v", err)
+	}
+	m.Match(func(r *http.Request) bool {
+		gr, err := parseGraphQLRequest(r)
+		if err != nil {
+			return false
+		}
+		got, err := normalizeJSON(gr.Variables)
+		if err != nil {
+			return false
+		}
+		return containsJSON(got, want)
+	})
+	return m
+}
+
+// ResponseData set handler which return GraphQL response with data.
+func (m *graphQLMatcher) ResponseData(data any) {
+	m.ResponseGraphQL(data)
+}
+
+// ResponseErrors set handler which return GraphQL response with errors and null data.
+func (m *graphQLMatcher) ResponseErrors(errs ...GraphQLError) {
+	m.ResponseGraphQL(nil, errs...)
+}
+
+// ResponseGraphQL set handler which return GraphQL response with data and errors.
+func (m *graphQLMatcher) ResponseGraphQL(data any, errs ...GraphQLError) {
+	b, err := json.Marshal(graphQLResponse{Data: data, Errors: errs})
+	if err != nil {
+		m.router.t.Fatalf("failed to convert message: %v", err)
+	}
+	// the handler is set directly since GraphQL responses are not checked by StrictStubs
+	m.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(b)
+	}
+}
+
+// parseGraphQLRequest parses GraphQL over HTTP request and determines the operation to be executed.
+func parseGraphQLRequest(r *http.Request) (*graphQLRequest, error) {
+	gr := &graphQLRequest{}
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		gr.Query = q.Get("query")
+		gr.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &gr.Variables); err != nil {
+				return nil, fmt.Errorf("invalid variables: %w", err)
+			}
+		}
+	case http.MethodPost:
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mt == "application/graphql" {
+			gr.Query = string(b)
+			break
+		}
+		if err := json.Unmarshal(b, gr); err != nil {
+			return nil, fmt.Errorf("invalid GraphQL request: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported method for GraphQL: %s", r.Method)
+	}
+	if gr.Query == "" {
+		return nil, errors.New("no GraphQL query")
+	}
+	ops, err := parseGraphQLOperations(gr.Query)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range ops {
+		if gr.OperationName == "" || op.name == gr.OperationName {
+			if gr.OperationName == "" && len(ops) > 1 {
+				return nil, errors.New("operationName is required for GraphQL document with multiple operations")
+			}
+			gr.OperationName = op.name
+			gr.operationType = op.typ
+			return gr, nil
+		}
+	}
+	return nil, fmt.Errorf("GraphQL operation not found: %s", gr.OperationName)
+}
+
+type graphQLOperation struct {
+	typ  string
+	name string
+}
+
+// parseGraphQLOperations scans GraphQL document and returns operation definitions (fragments are skipped).
+func parseGraphQLOperations(doc string) ([]graphQLOperation, error) {
+	var (
+		ops        []graphQLOperation
+		braceDepth int
+		parenDepth int
+		current    *graphQLOperation
+		expectName bool
+		inFragment bool
+	)
+	for i := 0; i < len(doc); {
+		c := doc[i]
+		switch {
+		case c == '#':
+			for i < len(doc) && doc[i] != '\n' {
+				i++
+			}
+			continue
+		case c == '"':
+			end, err := skipGraphQLString(doc, i)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+			continue
+		case c == '(':
+			parenDepth++
+			expectName = false
+		case c == ')':
+			parenDepth--
+		case c == '{':
+			if braceDepth == 0 && parenDepth == 0 {
+				if current == nil && !inFragment {
+					// shorthand query
+					ops = append(ops, graphQLOperation{typ: GraphQLQuery})
+				}
+				current = nil
+				expectName = false
+			}
+			braceDepth++
+		case c == '}':
+			braceDepth--
+			if braceDepth == 0 && parenDepth == 0 {
+				inFragment = false
+			}
+		case isGraphQLNameStart(c):
+			start := i
+			for i < len(doc) && isGraphQLNameContinue(doc[i]) {
+				i++
+			}
+			name := doc[start:i]
+			if braceDepth == 0 && parenDepth == 0 {
+				switch {
+				case expectName:
+					current.name = name
+					expectName = false
+				case current == nil && !inFragment:
+					switch name {
+					case GraphQLQuery, GraphQLMutation, GraphQLSubscription:
+						ops = append(ops, graphQLOperation{typ: name})
+						current = &ops[len(ops)-1]
+						expectName = true
+					case "fragment":
+						inFragment = true
+					}
+				}
+			}
+			continue
+		default:
+			if c == '@' || c == '$' {
+				expectName = false
+			}
+		}
+		i++
+	}
+	if braceDepth != 0 || parenDepth != 0 {
+		return nil, errors.New("invalid GraphQL document: unbalanced brackets")
+	}
+	if len(ops) == 0 {
+		return nil, errors.New("invalid GraphQL document: no operation")
+	}
+	return ops, nil
+}
+
+func skipGraphQLString(doc string, i int) (int, error) {
+	if strings.HasPrefix(doc[i:], `"""`) {
+		end := strings.Index(doc[i+3:], `"""`)
+		if end < 0 {
+			return 0, errors.New("invalid GraphQL document: unterminated block string")
+		}
+		return i + 3 + end + 3, nil
+	}
+	for j := i + 1; j < len(doc); j++ {
+		switch doc[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1, nil
+		}
+	}
+	return 0, errors.New("invalid GraphQL document: unterminated string")
+}
+
+func isGraphQLNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isGraphQLNameContinue(c byte) bool {
+	return isGraphQLNameStart(c) || (c >= '0' && c <= '9')
+}
+
+// normalizeJSON converts v into the generic form produced by encoding/json.
+func normalizeJSON(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var n any
+	if err := json.Unmarshal(b, &n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// containsJSON reports whether got contains want. Objects match when got has all keys of want.
+func containsJSON(got, want any) bool {
+	wm, ok := want.(map[string]any)
+	if !ok {
+		return reflect.DeepEqual(got, want)
+	}
+	gm, ok := got.(map[string]any)
+	if !ok {
+		return false
+	}
+	for k, wv := range wm {
+		gv, ok := gm[k]
+		if !ok || !containsJSON(gv, wv) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,171 @@
+package httpstub
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestGraphQL(t *testing.T) {
+	rt := NewRouter(t)
+	rt.GraphQL("/graphql").OperationName("GetUser").Variables(map[string]any{"id": 1}).ResponseData(map[string]any{"user": map[string]any{"name": "alice"}})
+	rt.GraphQL("/graphql").OperationName("GetUser").ResponseData(map[string]any{"user": nil})
+	rt.GraphQL("/graphql").OperationType(GraphQLMutation).ResponseErrors(GraphQLError{Message: "forbidden", Path: []any{"createUser"}})
+	rt.GraphQL("/graphql").OperationName("Empty").ResponseData(nil)
+	rt.GraphQL("/graphql").OperationType(GraphQLQuery).ResponseData(map[string]any{"ok": true})
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	tc := ts.Client()
+
+	tests := []struct {
+		name string
+		req  func() (*http.Response, error)
+		want string
+	}{
+		{
+			"match by operation name and variables",
+			func() (*http.Response, error) {
+				return postGraphQL(tc, `query GetUser($id: ID!) { user(id: $id) { name } }`, "", map[string]any{"id": 1, "extra": true})
+			},
+			`{"data":{"user":{"name":"alice"}}}`,
+		},
+		{
+			"variables do not match",
+			func() (*http.Response, error) {
+				return postGraphQL(tc, `query GetUser($id: ID!) { user(id: $id) { name } }`, "", map[string]any{"id": 2})
+			},
+			`{"data":{"user":null}}`,
+		},
+		{
+			"select operation by operationName",
+			func() (*http.Response, error) {
+				return postGraphQL(tc, `
+# comment with { brace
+query GetUser { user(id: "}") { ...F } }
+mutation CreateUser($in: In = {name: "x"}) { createUser(in: $in) { name } }
+fragment F on User { name }`, "CreateUser", nil)
+			},
+			`{"data":null,"errors":[{"message":"forbidden","path":["createUser"]}]}`,
+		},
+		{
+			"null data",
+			func() (*http.Response, error) {
+				return postGraphQL(tc, `query Empty { viewer { name } }`, "", nil)
+			},
+			`{"data":null}`,
+		},
+		{
+			"shorthand query via GET",
+			func() (*http.Response, error) {
+				return tc.Get("https://example.com/graphql?query=" + url.QueryEscape(`{ viewer { name } }`))
+			},
+			`{"data":{"ok":true}}`,
+		},
+		{
+			"application/graphql body",
+			func() (*http.Response, error) {
+				return tc.Post("https://example.com/graphql", "application/graphql", strings.NewReader(`query Other { viewer { name } }`))
+			},
+			`{"data":{"ok":true}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.req()
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				res.Body.Close()
+			})
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := res.Header.Get("Content-Type"); got != "application/json" {
+				t.Errorf("got %v\nwant %v", got, "application/json")
+			}
+			if got := string(body); got != tt.want {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphQLWithOpenAPI3(t *testing.T) {
+	// GraphQL endpoints are neither validated nor checked by StrictStubs with OpenAPI Document
+	rt := NewRouter(t, OpenApi3("testdata/openapi3.yml"), StrictStubs(true))
+	m := rt.GraphQL("/graphql")
+	m.ResponseData(map[string]any{"ok": false})
+	m.ResponseData(map[string]any{"ok": true})
+	if got := len(m.middlewares); got != 0 {
+		t.Errorf("got %v\nwant %v", got, 0)
+	}
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	res, err := postGraphQL(ts.Client(), `{ viewer { name } }`, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		res.Body.Close()
+	})
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Header.Values("Content-Type"); len(got) != 1 || got[0] != "application/json" {
+		t.Errorf("got %v\nwant %v", got, "application/json")
+	}
+	if got, want := string(body), `{"data":{"ok":true}}`; got != want {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+}
+
+func TestParseGraphQLOperations(t *testing.T) {
+	tests := []struct {
+		doc     string
+		want    []graphQLOperation
+		wantErr bool
+	}{
+		{`{ a }`, []graphQLOperation{{GraphQLQuery, ""}}, false},
+		{`query { a }`, []graphQLOperation{{GraphQLQuery, ""}}, false},
+		{`query Q($a: Int = 1) @dir { a }`, []graphQLOperation{{GraphQLQuery, "Q"}}, false},
+		{`subscription OnEvent { e } mutation M { m }`, []graphQLOperation{{GraphQLSubscription, "OnEvent"}, {GraphQLMutation, "M"}}, false},
+		{`fragment F on T { a } query Q { ...F }`, []graphQLOperation{{GraphQLQuery, "Q"}}, false},
+		{`query Q { a(s: """ } """) }`, []graphQLOperation{{GraphQLQuery, "Q"}}, false},
+		{`query Q { a `, nil, true},
+		{`fragment F on T { a }`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.doc, func(t *testing.T) {
+			got, err := parseGraphQLOperations(tt.doc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v\nwantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v\nwant %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("got %v\nwant %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func postGraphQL(tc *http.Client, query, operationName string, variables map[string]any) (*http.Response, error) {
+	b, err := json.Marshal(map[string]any{"query": query, "operationName": operationName, "variables": variables})
+	if err != nil {
+		return nil, err
+	}
+	return tc.Post("https://example.com/graphql", "application/json", strings.NewReader(string(b)))
+}
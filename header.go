@@ -0,0 +1,133 @@
+package httpstub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"go.yaml.in/yaml/v4"
+)
+
+// setResponseHeaders sets headers declared in the response of status using their examples or schemas.
+// Examples are preferred unless the response mode is AlwaysGenerate.
+func (m *matcher) setResponseHeaders(w http.ResponseWriter, responses *v3.Responses, status int) error {
+	res := findResponse(responses, status)
+	if res == nil || res.Headers == nil {
+		return nil
+	}
+	for name, h := range res.Headers.FromOldest() {
+		if h == nil || http.CanonicalHeaderKey(name) == "Content-Type" {
+			// Content-Type is determined by the media type
+			continue
+		}
+		if w.Header().Get(name) != "" {
+			// already set by the handler
+			continue
+		}
+		v, err := m.headerValue(h)
+		if err != nil {
+			return fmt.Errorf("failed to generate value of header %s: %w", name, err)
+		}
+		if v == nil {
+			continue
+		}
+		w.Header().Set(name, formatHeaderValue(v, h.Explode))
+	}
+	return nil
+}
+
+// isHeaderDeclared reports whether the response of status declares the header.
+func isHeaderDeclared(responses *v3.Responses, status int, name string) bool {
+	res := findResponse(responses, status)
+	if res == nil || res.Headers == nil {
+		return false
+	}
+	for n := range res.Headers.KeysFromOldest() {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerValue returns the value of the header from its example or schema.
+func (m *matcher) headerValue(h *v3.Header) (any, error) {
+	example := headerExample(h)
+	if example != nil && m.router.responseMode != AlwaysGenerate {
+		return decodeYAMLNode(example)
+	}
+	if h.Schema != nil {
+		b, err := m.router.generator.generateJSON(h.Schema.Schema())
+		if err != nil {
+			return nil, err
+		}
+		var v any
+		if err := json.Unmarshal(b, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	if example != nil {
+		return decodeYAMLNode(example)
+	}
+	return nil, nil
+}
+
+func headerExample(h *v3.Header) *yaml.Node {
+	if h.Example != nil {
+		return h.Example
+	}
+	if h.Examples != nil {
+		for _, ex := range h.Examples.FromOldest() {
+			if ex != nil && ex.Value != nil {
+				return ex.Value
+			}
+		}
+	}
+	return nil
+}
+
+func decodeYAMLNode(n *yaml.Node) (any, error) {
+	var v any
+	if err := n.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// formatHeaderValue formats the value using the simple style of OpenAPI header serialization.
+func formatHeaderValue(v any, explode bool) string {
+	switch vv := v.(type) {
+	case []any:
+		s := make([]string, 0, len(vv))
+		for _, e := range vv {
+			s = append(s, formatHeaderValue(e, explode))
+		}
+		return strings.Join(s, ",")
+	case map[string]any:
+		keys := make([]string, 0, len(vv))
+		for k := range vv {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		s := make([]string, 0, len(vv)*2)
+		for _, k := range keys {
+			if explode {
+				s = append(s, k+"="+formatHeaderValue(vv[k], explode))
+			} else {
+				s = append(s, k, formatHeaderValue(vv[k], explode))
+			}
+		}
+		return strings.Join(s, ",")
+	case float64:
+		return strconv.FormatFloat(vv, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(vv)
+	}
+}
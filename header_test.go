@@ -0,0 +1,72 @@
+package httpstub
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestResponseDynamicHeaders(t *testing.T) {
+	tests := []struct {
+		mode         ResponseMode
+		wantLocation string
+		wantTags     string
+	}{
+		{PreferExamples, "/api/v1/items/1", "a,b"},
+		{AlwaysGenerate, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(int(tt.mode)), func(t *testing.T) {
+			rt := NewRouter(t, OpenApi3("testdata/openapi3-headers.yml"), DynamicResponseMode(tt.mode))
+			rt.Method(http.MethodPost).Path("/api/v1/items").ResponseDynamic()
+			ts := rt.Server()
+			t.Cleanup(func() {
+				ts.Close()
+			})
+			res, err := ts.Client().Do(newRequest(t, http.MethodPost, "https://example.com/api/v1/items", `{"name":"alice"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				res.Body.Close()
+			})
+			location := res.Header.Get("Location")
+			if location == "" {
+				t.Error("Location header is not set")
+			}
+			if tt.wantLocation != "" && location != tt.wantLocation {
+				t.Errorf("got %v\nwant %v", location, tt.wantLocation)
+			}
+			if got, err := strconv.Atoi(res.Header.Get("X-Rate-Limit")); err != nil || got < 1 {
+				t.Errorf("got %v\nwant positive integer", res.Header.Get("X-Rate-Limit"))
+			}
+			if tt.wantTags != "" && res.Header.Get("X-Tags") != tt.wantTags {
+				t.Errorf("got %v\nwant %v", res.Header.Get("X-Tags"), tt.wantTags)
+			}
+			if got := res.Header.Get("Content-Type"); got != "application/json" {
+				t.Errorf("got %v\nwant %v", got, "application/json")
+			}
+		})
+	}
+}
+
+func TestFormatHeaderValue(t *testing.T) {
+	tests := []struct {
+		v       any
+		explode bool
+		want    string
+	}{
+		{"abc", false, "abc"},
+		{float64(5), false, "5"},
+		{true, false, "true"},
+		{[]any{"a", float64(1)}, false, "a,1"},
+		{map[string]any{"b": "2", "a": "1"}, false, "a,1,b,2"},
+		{map[string]any{"b": "2", "a": "1"}, true, "a=1,b=2"},
+		{nil, false, ""},
+	}
+	for _, tt := range tests {
+		if got := formatHeaderValue(tt.v, tt.explode); got != tt.want {
+			t.Errorf("got %v\nwant %v", got, tt.want)
+		}
+	}
+}
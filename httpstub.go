@@ -23,14 +23,10 @@ import (
 	"time"
 
 	wildcard "github.com/IGLOU-EU/go-wildcard/v2"
-	"github.com/pb33f/libopenapi"
-	validator "github.com/pb33f/libopenapi-validator"
 	validatorconfig "github.com/pb33f/libopenapi-validator/config"
 	"github.com/pb33f/libopenapi-validator/paths"
 	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
-	openapijson "github.com/pb33f/libopenapi/json"
 	"github.com/pb33f/libopenapi/orderedmap"
-	"github.com/pb33f/libopenapi/renderer"
 	"go.yaml.in/yaml/v4"
 )
 
@@ -73,20 +69,38 @@ type Router struct {
 	useTLS                              bool
 	cacert, cert, key                   []byte
 	clientCacert, clientCert, clientKey []byte
-	openAPI3Doc                         libopenapi.Document
-	openAPI3Validator                   validator.Validator
+	openAPI3Specs                       []*openAPI3Spec
 	skipValidateRequest                 bool
 	skipValidateResponse                bool
+	rejectInvalidRequestStatus          int
+	strictStubs                         bool
+	validationWarnings                  bool
+	validationWarningsOutput            io.Writer
 	prependOnce                         bool
 	addr                                string
 	basePath                            string
-	mockGenerator                       *renderer.MockGenerator
+	generator                           *generator
 	rng                                 *mrand.Rand
 	responseMode                        ResponseMode
+	webSockets                          []*webSocketStub
+	coverageCollector                   *OpenAPICoverageCollector
+	coverageCheck                       func()
+	callbackClient                      *http.Client
+	callbacks                           sync.WaitGroup
+	callbacksCleanup                    sync.Once
+	ctx                                 context.Context
+	cancel                              context.CancelFunc
+	journalExchanges                    bool
+	exchanges                           []*exchange
 	mu                                  sync.RWMutex
 }
 
 type matcher struct {
+	// method, path and operation are the conditions of the matcher checked by StrictStubs
+	method      string
+	path        string
+	operation   *v3.Operation
+	validation  validationMode
 	matchFuncs  []matchFunc
 	handler     http.HandlerFunc
 	middlewares middlewareFuncs
@@ -95,6 +109,8 @@ type matcher struct {
 	mu          sync.RWMutex
 }
 
+type matcherKey struct{}
+
 type matchFunc func(r *http.Request) bool
 type middlewareFunc func(next http.HandlerFunc) http.HandlerFunc
 type middlewareFuncs []middlewareFunc
@@ -127,6 +143,8 @@ func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			rt.mu.RLock()
 			mws := append(rt.middlewares, m.middlewares...)
 			rt.mu.RUnlock()
+			// router middlewares (e.g. validation) refer to the matched matcher
+			r = r.WithContext(context.WithValue(r.Context(), matcherKey{}, m))
 			mws.then(m.handler).ServeHTTP(w, r)
 			return
 		}
@@ -140,23 +158,15 @@ func NewRouter(t TB, opts ...Option) *Router {
 	t.Helper()
 	c := &config{}
 
-	// Set skipCircularReferenceCheck first
-	for _, opt := range opts {
-		tmp := &config{}
-		_ = opt(tmp)
-		if tmp.skipCircularReferenceCheck {
-			if err := opt(c); err != nil {
-				t.Fatal(err)
-			}
-			break
-		}
-	}
-
 	for _, opt := range opts {
 		if err := opt(c); err != nil {
 			t.Fatal(err)
 		}
 	}
+	// OpenAPI Documents are loaded after all options are applied
+	if err := c.loadOpenAPI3Specs(); err != nil {
+		t.Fatal(err)
+	}
 
 	mode := c.responseMode
 	if mode == 0 {
@@ -164,36 +174,63 @@ func NewRouter(t TB, opts ...Option) *Router {
 	}
 
 	rt := &Router{
-		t:                    t,
-		useTLS:               c.useTLS,
-		cacert:               c.cacert,
-		cert:                 c.cert,
-		key:                  c.key,
-		clientCacert:         c.clientCacert,
-		clientCert:           c.clientCert,
-		clientKey:            c.clientKey,
-		openAPI3Doc:          c.openAPI3Doc,
-		openAPI3Validator:    c.openAPI3Validator,
-		skipValidateRequest:  c.skipValidateRequest,
-		skipValidateResponse: c.skipValidateResponse,
-		addr:                 c.addr,
-		basePath:             c.basePath,
-		responseMode:         mode,
+		t:                          t,
+		useTLS:                     c.useTLS,
+		cacert:                     c.cacert,
+		cert:                       c.cert,
+		key:                        c.key,
+		clientCacert:               c.clientCacert,
+		clientCert:                 c.clientCert,
+		clientKey:                  c.clientKey,
+		openAPI3Specs:              c.openAPI3Specs,
+		skipValidateRequest:        c.skipValidateRequest,
+		skipValidateResponse:       c.skipValidateResponse,
+		rejectInvalidRequestStatus: c.rejectInvalidRequestStatus,
+		strictStubs:                c.strictStubs,
+		validationWarnings:         c.validationWarnings,
+		validationWarningsOutput:   c.validationWarningsOutput,
+		addr:                       c.addr,
+		basePath:                   c.basePath,
+		responseMode:               mode,
+		callbackClient:             c.callbackClient,
+		journalExchanges:           c.journalExchanges,
+	}
+	// ctx is canceled on Close to stop waiting for delayed callbacks
+	rt.ctx, rt.cancel = context.WithCancel(context.Background())
+	// coverage middleware must be the outermost so that responses of chaos, security and validation are recorded
+	if err := rt.setOpenAPICoverage(c.coverage); err != nil {
+		t.Fatal(err)
+	}
+	if c.chaos != nil {
+		// chaos middleware must be outside validation so that injected failures bypass validation
+		rt.middlewares = append(rt.middlewares, rt.chaosMiddleware(c.chaos))
+	}
+	if rt.journalExchanges && len(rt.openAPI3Specs) > 0 {
+		// journal exchanges for ContractReport (including responses rejected by security and validation)
+		rt.middlewares = append(rt.middlewares, rt.journalMiddleware())
+	}
+	if c.security != nil {
+		if len(rt.openAPI3Specs) == 0 {
+			t.Fatal("EnforceSecurity requires OpenAPI v3 document")
+		}
+		// security middleware rejects requests before validation as an API gateway does
+		rt.middlewares = append(rt.middlewares, rt.securityMiddleware(c.security))
 	}
 	if err := rt.setOpenApi3Vaildator(); err != nil {
 		t.Fatal(err)
 	}
 
-	// Initialize MockGenerator (use JSON mock type) and seed math/rand for deterministic example selection in tests
-	mg := renderer.NewMockGenerator(renderer.JSON)
+	// Initialize generator and seed math/rand for deterministic example selection in tests
 	var seed int64
 	if c.seed != 0 {
 		seed = c.seed
 	} else {
 		seed = time.Now().UnixNano()
 	}
-	mg.SetSeed(seed)
-	rt.mockGenerator = mg
+	rt.generator = newGenerator(seed, c.formatGenerators, c.schemaGenerators)
+	if !c.useConstraintGenerator && len(c.formatGenerators) == 0 && len(c.schemaGenerators) == 0 {
+		rt.generator.useMock(seed)
+	}
 	var seedBytes [32]byte
 	binary.LittleEndian.PutUint64(seedBytes[:8], uint64(seed)) //nolint:gosec
 	rt.rng = mrand.New(mrand.NewChaCha8(seedBytes))            //nolint:gosec
@@ -367,7 +404,21 @@ func (rt *Router) Close() {
 		rt.t.Error("server is not started yet")
 		return
 	}
+	// hijacked WebSocket connections are not closed by *httptest.Server
+	rt.mu.RLock()
+	for _, ws := range rt.webSockets {
+		ws.closeConns()
+	}
+	rt.mu.RUnlock()
+	// close the server first so that no handler fires callbacks while waiting for them
 	rt.server.Close()
+	// callbacks waiting for the delay or being fired are canceled
+	rt.cancel()
+	rt.callbacks.Wait()
+	if rt.coverageCheck != nil {
+		// TB without Cleanup checks OpenAPI coverage on Close
+		rt.coverageCheck()
+	}
 }
 
 // Match create request matcher with matchFunc (func(r *http.Request) bool).
@@ -396,6 +447,7 @@ func (rt *Router) Method(method string) *matcher {
 	defer rt.mu.Unlock()
 	fn := methodMatchFunc(method)
 	m := &matcher{
+		method:     method,
 		matchFuncs: []matchFunc{fn},
 		router:     rt,
 	}
@@ -408,6 +460,7 @@ func (m *matcher) Method(method string) *matcher {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	fn := methodMatchFunc(method)
+	m.method = method
 	m.matchFuncs = append(m.matchFuncs, fn)
 	return m
 }
@@ -418,6 +471,7 @@ func (rt *Router) Path(path string) *matcher {
 	defer rt.mu.Unlock()
 	fn := pathMatchFunc(path)
 	m := &matcher{
+		path:       path,
 		matchFuncs: []matchFunc{fn},
 		router:     rt,
 	}
@@ -430,6 +484,7 @@ func (m *matcher) Path(path string) *matcher {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	fn := pathMatchFunc(path)
+	m.path = path
 	m.matchFuncs = append(m.matchFuncs, fn)
 	return m
 }
@@ -451,6 +506,7 @@ func (rt *Router) Query(key, value string) *matcher {
 	fn := queryMatchFunc(key, value)
 	m := &matcher{
 		matchFuncs: []matchFunc{fn},
+		router:     rt,
 	}
 	rt.addMatcher(m)
 	return m
@@ -514,6 +570,11 @@ func (m *matcher) Handler(fn func(w http.ResponseWriter, r *http.Request)) {
 
 // Response set handler which return response (status and body).
 func (m *matcher) Response(status int, body any) {
+	if m.router.strictStubs {
+		if err := m.checkStub(status); err != nil {
+			m.router.t.Fatalf("httpstub error: %v", err)
+		}
+	}
 	var (
 		b   []byte
 		err error
@@ -551,7 +612,11 @@ func (m *matcher) ResponseStringf(status int, format string, a ...any) {
 }
 
 type responseExampleConfig struct {
-	status string
+	status      string
+	example     string
+	echoRequest bool
+	overrides   []override
+	callbacks   *callbackConfig
 }
 
 func newResponseExampleConfig() *responseExampleConfig {
@@ -570,6 +635,52 @@ func Status(pattern string) responseExampleOption {
 	}
 }
 
+// Example specify the named example to use in the response.
+// The response is chosen from the responses (matching Status) which have the example.
+func Example(name string) responseExampleOption {
+	return func(c *responseExampleConfig) error {
+		if name == "" {
+			return errors.New("example name must not be empty")
+		}
+		c.example = name
+		return nil
+	}
+}
+
+// preference is a preference for dynamic response specified by Prefer header (compatible with Prism).
+type preference struct {
+	code    string
+	example string
+	dynamic *bool
+}
+
+// parsePreferHeader parses Prefer header such as `code=404, example=notFound, dynamic=true`.
+func parsePreferHeader(values []string) (*preference, error) {
+	p := &preference{}
+	for _, v := range values {
+		for pref := range strings.SplitSeq(v, ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(pref), "=")
+			value = strings.Trim(strings.TrimSpace(value), `"`)
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "code":
+				if _, err := strconv.Atoi(value); err != nil {
+					return nil, fmt.Errorf("invalid code of Prefer header: %s", value)
+				}
+				p.code = value
+			case "example":
+				p.example = value
+			case "dynamic":
+				d, err := strconv.ParseBool(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid dynamic of Prefer header: %s", value)
+				}
+				p.dynamic = &d
+			}
+		}
+	}
+	return p, nil
+}
+
 // selectMatchedResponses returns responses that match the given status pattern.
 func (m *matcher) selectMatchedResponses(responses *v3.Responses, pattern string) ([]orderedmap.Pair[string, *v3.Response], error) {
 	resMap := responses.Codes
@@ -587,8 +698,14 @@ func (m *matcher) selectMatchedResponses(responses *v3.Responses, pattern string
 
 // pickStatusAndResponse selects one of the matched responses (randomly) and returns its status and response.
 func (m *matcher) pickStatusAndResponse(matchedResps []orderedmap.Pair[string, *v3.Response]) (int, *v3.Response, string, error) {
+	m.router.mu.Lock()
 	idx := m.router.rng.IntN(len(matchedResps))
+	m.router.mu.Unlock()
 	statusStr := matchedResps[idx].Key()
+	if len(statusStr) == 3 && strings.EqualFold(statusStr[1:], "XX") {
+		// range of status codes such as 2XX responds the first status code of the range
+		statusStr = statusStr[:1] + "00"
+	}
 	status, err := strconv.Atoi(statusStr)
 	if err != nil {
 		return 0, nil, "", fmt.Errorf("invalid status code: %w", err)
@@ -630,7 +747,7 @@ func (m *matcher) genMockFromMediaType(mt *v3.MediaType) (*yaml.Node, error) {
 	if mt == nil || mt.Schema == nil {
 		return nil, fmt.Errorf("no schema available to generate mock")
 	}
-	mockBytes, genErr := m.router.mockGenerator.GenerateMock(mt.Schema.Schema(), "")
+	mockBytes, genErr := m.router.generator.generateJSON(mt.Schema.Schema())
 	if genErr != nil {
 		return nil, fmt.Errorf("failed to generate mock data: %w", genErr)
 	}
@@ -661,6 +778,51 @@ func (m *matcher) selectExample(mt *v3.MediaType, status int, contentType string
 	return 0, nil, "", false
 }
 
+// findNamedExample is a helper method to find the named example from the responses matching the pattern.
+func (m *matcher) findNamedExample(req *http.Request, responses *v3.Responses, pattern, name string) (status int, exampleNode *yaml.Node, contentType string, err error) {
+	matchedResps, err := m.selectMatchedResponses(responses, pattern)
+	if err != nil {
+		return 0, nil, "", err
+	}
+	var withExample []orderedmap.Pair[string, *v3.Response]
+	for _, p := range matchedResps {
+		if _, _, ok := namedExample(p.Value(), "", name); ok {
+			withExample = append(withExample, p)
+		}
+	}
+	if len(withExample) == 0 {
+		return 0, nil, "", fmt.Errorf("failed to find example %q in responses matching pattern: %s", name, pattern)
+	}
+	status, res, _, err := m.pickStatusAndResponse(withExample)
+	if err != nil {
+		return 0, nil, "", err
+	}
+	_, contentType = m.pickMediaType(res, req)
+	node, contentType, _ := namedExample(res, contentType, name)
+	return status, node, contentType, nil
+}
+
+// namedExample returns the named example of the response, preferring the content type.
+func namedExample(res *v3.Response, contentType, name string) (*yaml.Node, string, bool) {
+	if res == nil || res.Content == nil {
+		return nil, "", false
+	}
+	if mt, ok := res.Content.Get(contentType); ok && mt != nil && mt.Examples != nil {
+		if ex, ok := mt.Examples.Get(name); ok && ex != nil {
+			return ex.Value, contentType, true
+		}
+	}
+	for ct, mt := range res.Content.FromOldest() {
+		if mt == nil || mt.Examples == nil {
+			continue
+		}
+		if ex, ok := mt.Examples.Get(name); ok && ex != nil {
+			return ex.Value, ct, true
+		}
+	}
+	return nil, "", false
+}
+
 // generateFromSchema generates a mock response from the schema of the media type.
 func (m *matcher) generateFromSchema(mt *v3.MediaType, status int, contentType string) (int, *yaml.Node, string, error) {
 	if mt != nil && mt.Schema != nil {
@@ -735,7 +897,7 @@ func (m *matcher) findResponseContentAuto(req *http.Request, responses *v3.Respo
 // ResponseDynamic set handler which return response from OpenAPI v3 Document.
 // The response mode is determined by the Router's responseMode.
 func (m *matcher) ResponseDynamic(opts ...responseExampleOption) {
-	if m.router.openAPI3Doc == nil {
+	if len(m.router.openAPI3Specs) == 0 {
 		m.router.t.Error("no OpenAPI v3 document is set")
 		return
 	}
@@ -747,15 +909,17 @@ func (m *matcher) ResponseDynamic(opts ...responseExampleOption) {
 		}
 	}
 
-	doc := m.router.openAPI3Doc
-	v3m, err := doc.BuildV3Model()
-	if err != nil {
-		m.router.t.Errorf("failed to build OpenAPI v3 model: %v", err)
-		return
-	}
 	validationOpts := &validatorconfig.ValidationOptions{RegexCache: &sync.Map{}}
 	fn := func(w http.ResponseWriter, r *http.Request) {
-		pathItem, errs, pathValue := paths.FindPath(r, &v3m.Model, validationOpts)
+		spec, sr := m.router.findOpenAPI3Spec(r)
+		if spec == nil {
+			m.router.t.Errorf("failed to find OpenAPI v3 document for %v %v", r.Method, r.URL.Path)
+			return
+		}
+		// the path of the request to the document is stripped of the prefix the document is mounted under
+		orig := r
+		r = sr
+		pathItem, errs, pathValue := paths.FindPath(r, spec.model, validationOpts)
 		if pathItem == nil || errs != nil {
 			var err error
 			for _, e := range errs {
@@ -775,14 +939,39 @@ func (m *matcher) ResponseDynamic(opts ...responseExampleOption) {
 		var contentType string
 		var err error
 
-		// Select response generation method based on router's response mode
-		switch m.router.responseMode {
-		case AlwaysGenerate:
-			status, exampleNode, contentType, err = m.findResponseContentDynamic(r, op.Responses, c.status)
-		case ExamplesOnly:
-			status, exampleNode, contentType, err = m.findResponseExample(r, op.Responses, c.status)
-		case PreferExamples:
-			status, exampleNode, contentType, err = m.findResponseContentAuto(r, op.Responses, c.status)
+		// Prefer header overrides the options per request
+		pattern, example, mode := c.status, c.example, m.router.responseMode
+		if values := r.Header.Values("Prefer"); len(values) > 0 {
+			p, err := parsePreferHeader(values)
+			if err != nil {
+				m.router.t.Errorf("failed to parse Prefer header of route (%v %v): %v", r.Method, pathValue, err)
+				return
+			}
+			if p.code != "" {
+				pattern = p.code
+			}
+			if p.example != "" {
+				example = p.example
+			}
+			if p.dynamic != nil {
+				if *p.dynamic {
+					mode, example = AlwaysGenerate, ""
+				} else {
+					mode = PreferExamples
+				}
+			}
+		}
+
+		// Select response generation method based on response mode
+		switch {
+		case example != "":
+			status, exampleNode, contentType, err = m.findNamedExample(r, op.Responses, pattern, example)
+		case mode == AlwaysGenerate:
+			status, exampleNode, contentType, err = m.findResponseContentDynamic(r, op.Responses, pattern)
+		case mode == ExamplesOnly:
+			status, exampleNode, contentType, err = m.findResponseExample(r, op.Responses, pattern)
+		case mode == PreferExamples:
+			status, exampleNode, contentType, err = m.findResponseContentAuto(r, op.Responses, pattern)
 		default:
 			// This should never happen as responseMode is always set to a valid value in NewRouter
 			m.router.t.Fatalf("invalid response mode: %v", m.router.responseMode)
@@ -793,18 +982,36 @@ func (m *matcher) ResponseDynamic(opts ...responseExampleOption) {
 			m.router.t.Errorf("failed to generate response for route (%v %v): %v", r.Method, pathValue, err)
 			return
 		}
+		if exampleNode != nil && (c.echoRequest || len(c.overrides) > 0) {
+			// copy not to modify examples in the document
+			exampleNode = cloneYAMLNode(exampleNode)
+			if c.echoRequest {
+				echoValues(exampleNode, responseSchema(op.Responses, status, contentType), requestValues(r, spec.model, pathValue))
+			}
+			if err := applyOverrides(exampleNode, c.overrides); err != nil {
+				m.router.t.Errorf("failed to generate response for route (%v %v): %v", r.Method, pathValue, err)
+				return
+			}
+		}
 		var b []byte
 		if exampleNode != nil {
-			b, err = openapijson.YAMLNodeToJSON(exampleNode, "  ")
+			b, err = encodeBody(exampleNode, responseSchemaProxy(op.Responses, status, contentType), contentType)
 			if err != nil {
 				m.router.t.Errorf("failed to marshal body of route (%v %v %v)", status, r.Method, pathValue)
 				return
 			}
 		}
 
+		if err := m.setResponseHeaders(w, op.Responses, status); err != nil {
+			m.router.t.Errorf("failed to generate response headers of route (%v %v %v): %v", status, r.Method, pathValue, err)
+			return
+		}
 		w.Header().Set("Content-Type", contentType)
 		w.WriteHeader(status)
 		_, _ = w.Write(b)
+		if c.callbacks != nil {
+			m.router.fireCallbacks(c.callbacks, op, callbackContext(orig, r, spec.model, pathValue, status, w.Header(), b))
+		}
 	}
 	m.handler = http.HandlerFunc(fn)
 }
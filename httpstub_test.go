@@ -2,10 +2,12 @@ package httpstub
 
 import (
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -1348,3 +1350,85 @@ func BenchmarkNewServer(b *testing.B) {
 		ts.Close()
 	}
 }
+
+func TestResponseDynamicExample(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []responseExampleOption
+		prefer     string
+		path       string
+		wantStatus int
+		want       string
+	}{
+		{"named example", []responseExampleOption{Example("notFound")}, "", "/api/v1/items/1", http.StatusNotFound, `{"error":"item not found"}`},
+		{"named example with status", []responseExampleOption{Status("404"), Example("ex1")}, "", "/api/v1/users", http.StatusNotFound, `{"error":"Not found"}`},
+		{"Prefer code", nil, "code=404, dynamic=false", "/api/v1/users", http.StatusNotFound, `{"error":"Not found"}`},
+		{"Prefer code and example", nil, `code=200, example="ex1"`, "/api/v1/users", http.StatusOK, `[{"username":"alice"},{"username":"bob"}]`},
+		{"Prefer example", nil, "example=notFound", "/api/v1/items/1", http.StatusNotFound, `{"error":"item not found"}`},
+		{"Prefer dynamic=false", []responseExampleOption{Status("404")}, "dynamic=false", "/api/v1/users", http.StatusNotFound, `{"error":"Not found"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := NewRouter(t, OpenApi3("testdata/openapi3-examples.yml"))
+			rt.Method(http.MethodGet).ResponseDynamic(tt.opts...)
+			ts := rt.Server()
+			t.Cleanup(func() {
+				ts.Close()
+			})
+			req := newRequest(t, http.MethodGet, "https://example.com"+tt.path, "")
+			if tt.prefer != "" {
+				req.Header.Set("Prefer", tt.prefer)
+			}
+			res, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				res.Body.Close()
+			})
+			if got := res.StatusCode; got != tt.wantStatus {
+				t.Errorf("got %v\nwant %v", got, tt.wantStatus)
+			}
+			b, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got, want any
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %s\nwant %v", b, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePreferHeader(t *testing.T) {
+	tr := true
+	tests := []struct {
+		values  []string
+		want    preference
+		wantErr bool
+	}{
+		{[]string{"code=404, example=notFound, dynamic=true"}, preference{code: "404", example: "notFound", dynamic: &tr}, false},
+		{[]string{"respond-async", `example="a b"`}, preference{example: "a b"}, false},
+		{[]string{"code=abc"}, preference{}, true},
+		{[]string{"dynamic=maybe"}, preference{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parsePreferHeader(tt.values)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("got error %v\nwantErr %v", err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if got.code != tt.want.code || got.example != tt.want.example || (got.dynamic == nil) != (tt.want.dynamic == nil) {
+			t.Errorf("got %+v\nwant %+v", got, tt.want)
+		}
+	}
+}
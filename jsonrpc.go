@@ -0,0 +1,236 @@
+package httpstub
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	JSONRPCParseError     = -32700
+	JSONRPCInvalidRequest = -32600
+	JSONRPCMethodNotFound = -32601
+	JSONRPCInvalidParams  = -32602
+	JSONRPCInternalError  = -32603
+)
+
+const jsonRPCVersion = "2.0"
+
+// JSONRPCError is an error object of JSON-RPC 2.0.
+type JSONRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *JSONRPCError) Error() string {
+	return e.Message
+}
+
+type jsonRPCStub struct {
+	matcher *matcher
+	methods []*jsonRPCMethod
+	mu      sync.RWMutex
+}
+
+type jsonRPCMethod struct {
+	name      string
+	params    any
+	hasParams bool
+	handler   func(params json.RawMessage) (any, error)
+	stub      *jsonRPCStub
+}
+
+type jsonRPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  any             `json:"result,omitempty"`
+	Error   *JSONRPCError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// JSONRPC create request matcher for JSON-RPC 2.0 endpoint using path, and returns the stub which dispatches calls by method and params.
+// Batch requests and notifications are supported.
+func (rt *Router) JSONRPC(path string) *jsonRPCStub {
+	m := rt.Method(http.MethodPost).Path(path)
+	s := &jsonRPCStub{matcher: m}
+	m.Header("Content-Type", "application/json").Handler(s.serveHTTP)
+	return s
+}
+
+// Method create method stub using JSON-RPC method name.
+func (s *jsonRPCStub) Method(name string) *jsonRPCMethod {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := &jsonRPCMethod{name: name, stub: s}
+	s.methods = append(s.methods, m)
+	return m
+}
+
+// Requests returns []*http.Request received by the JSON-RPC endpoint.
+func (s *jsonRPCStub) Requests() []*http.Request {
+	return s.matcher.Requests()
+}
+
+// Params set params which the call must have.
+// Object params match when the call has all keys of params; other params must be equal.
+func (m *jsonRPCMethod) Params(params any) *jsonRPCMethod {
+	p, err := normalizeJSON(params)
+	if err != nil {
+		m.stub.matcher.router.t.Fatalf("failed to convert params: %v", err)
+	}
+	m.stub.mu.Lock()
+	defer m.stub.mu.Unlock()
+	m.params = p
+	m.hasParams = true
+	return m
+}
+
+// Result set handler which return result.
+func (m *jsonRPCMethod) Result(result any) {
+	m.Handler(func(_ json.RawMessage) (any, error) {
+		return result, nil
+	})
+}
+
+// Error set handler which return error object.
+func (m *jsonRPCMethod) Error(code int, message string, data any) {
+	m.Handler(func(_ json.RawMessage) (any, error) {
+		return nil, &JSONRPCError{Code: code, Message: message, Data: data}
+	})
+}
+
+// Handler set handler which return result or error using params.
+// If the returned error is not *JSONRPCError, it is returned as an internal error.
+func (m *jsonRPCMethod) Handler(fn func(params json.RawMessage) (any, error)) {
+	m.stub.mu.Lock()
+	defer m.stub.mu.Unlock()
+	m.handler = fn
+}
+
+func (s *jsonRPCStub) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.matcher.router.t.Errorf("failed to read JSON-RPC request: %v", err)
+		return
+	}
+	b = bytes.TrimSpace(b)
+	var res any
+	switch {
+	case !json.Valid(b):
+		res = newJSONRPCErrorResponse(nil, JSONRPCParseError, "Parse error")
+	case len(b) > 0 && b[0] == '[':
+		var calls []json.RawMessage
+		if err := json.Unmarshal(b, &calls); err != nil || len(calls) == 0 {
+			res = newJSONRPCErrorResponse(nil, JSONRPCInvalidRequest, "Invalid Request")
+			break
+		}
+		var batch []*jsonRPCResponse
+		for _, c := range calls {
+			if cr := s.call(c); cr != nil {
+				batch = append(batch, cr)
+			}
+		}
+		if len(batch) > 0 {
+			res = batch
+		}
+	default:
+		if cr := s.call(b); cr != nil {
+			res = cr
+		}
+	}
+	if res == nil {
+		// all calls are notifications
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	rb, err := json.Marshal(res)
+	if err != nil {
+		s.matcher.router.t.Errorf("failed to convert JSON-RPC response: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(rb)
+}
+
+// call dispatches a single call. It returns nil for notifications.
+func (s *jsonRPCStub) call(b json.RawMessage) *jsonRPCResponse {
+	var req map[string]json.RawMessage
+	if err := json.Unmarshal(b, &req); err != nil {
+		return newJSONRPCErrorResponse(nil, JSONRPCInvalidRequest, "Invalid Request")
+	}
+	id, hasID := req["id"]
+	var version, method string
+	if err := json.Unmarshal(req["jsonrpc"], &version); err != nil || version != jsonRPCVersion {
+		return newJSONRPCErrorResponse(id, JSONRPCInvalidRequest, "Invalid Request")
+	}
+	if err := json.Unmarshal(req["method"], &method); err != nil || method == "" {
+		return newJSONRPCErrorResponse(id, JSONRPCInvalidRequest, "Invalid Request")
+	}
+	params := req["params"]
+	var got any
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &got); err != nil {
+			return newJSONRPCErrorResponse(id, JSONRPCInvalidParams, "Invalid params")
+		}
+	}
+
+	s.mu.RLock()
+	var (
+		handler func(params json.RawMessage) (any, error)
+		found   bool
+	)
+	for _, m := range s.methods {
+		if m.name != method || m.handler == nil {
+			continue
+		}
+		found = true
+		if m.hasParams && !containsJSON(got, m.params) {
+			continue
+		}
+		handler = m.handler
+		break
+	}
+	s.mu.RUnlock()
+
+	var res *jsonRPCResponse
+	switch {
+	case handler != nil:
+		result, err := handler(params)
+		if err != nil {
+			var rpcErr *JSONRPCError
+			if !errors.As(err, &rpcErr) {
+				rpcErr = &JSONRPCError{Code: JSONRPCInternalError, Message: err.Error()}
+			}
+			res = &jsonRPCResponse{JSONRPC: jsonRPCVersion, Error: rpcErr, ID: id}
+		} else {
+			if result == nil {
+				// result is required on success
+				result = json.RawMessage("null")
+			}
+			res = &jsonRPCResponse{JSONRPC: jsonRPCVersion, Result: result, ID: id}
+		}
+	case found:
+		res = newJSONRPCErrorResponse(id, JSONRPCInvalidParams, "Invalid params")
+	default:
+		res = newJSONRPCErrorResponse(id, JSONRPCMethodNotFound, "Method not found")
+	}
+	if !hasID {
+		return nil
+	}
+	return res
+}
+
+func newJSONRPCErrorResponse(id json.RawMessage, code int, message string) *jsonRPCResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &jsonRPCResponse{
+		JSONRPC: jsonRPCVersion,
+		Error:   &JSONRPCError{Code: code, Message: message},
+		ID:      id,
+	}
+}
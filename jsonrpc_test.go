@@ -0,0 +1,86 @@
+package httpstub
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestJSONRPC(t *testing.T) {
+	rt := NewRouter(t)
+	rpc := rt.JSONRPC("/rpc")
+	rpc.Method("eth_blockNumber").Result("0x10")
+	rpc.Method("eth_getBalance").Params([]any{"0xabc", "latest"}).Result("0x0")
+	rpc.Method("textDocument/hover").Params(map[string]any{"position": map[string]any{"line": 1}}).Result(map[string]any{"contents": "doc"})
+	rpc.Method("fail").Error(-32000, "boom", map[string]any{"reason": "test"})
+	rpc.Method("echo").Handler(func(params json.RawMessage) (any, error) {
+		var p []string
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		if len(p) == 0 {
+			return nil, errors.New("no params")
+		}
+		return p[0], nil
+	})
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	tc := ts.Client()
+
+	tests := []struct {
+		name       string
+		req        string
+		wantStatus int
+		want       string
+	}{
+		{"result", `{"jsonrpc":"2.0","method":"eth_blockNumber","id":1}`, http.StatusOK, `{"jsonrpc":"2.0","result":"0x10","id":1}`},
+		{"string id", `{"jsonrpc":"2.0","method":"eth_blockNumber","id":"abc"}`, http.StatusOK, `{"jsonrpc":"2.0","result":"0x10","id":"abc"}`},
+		{"params", `{"jsonrpc":"2.0","method":"eth_getBalance","params":["0xabc","latest"],"id":2}`, http.StatusOK, `{"jsonrpc":"2.0","result":"0x0","id":2}`},
+		{"named params", `{"jsonrpc":"2.0","method":"textDocument/hover","params":{"textDocument":{"uri":"a.go"},"position":{"line":1}},"id":3}`, http.StatusOK, `{"jsonrpc":"2.0","result":{"contents":"doc"},"id":3}`},
+		{"invalid params", `{"jsonrpc":"2.0","method":"eth_getBalance","params":["0xdef","latest"],"id":4}`, http.StatusOK, `{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params"},"id":4}`},
+		{"error", `{"jsonrpc":"2.0","method":"fail","id":5}`, http.StatusOK, `{"jsonrpc":"2.0","error":{"code":-32000,"message":"boom","data":{"reason":"test"}},"id":5}`},
+		{"handler", `{"jsonrpc":"2.0","method":"echo","params":["hello"],"id":6}`, http.StatusOK, `{"jsonrpc":"2.0","result":"hello","id":6}`},
+		{"handler internal error", `{"jsonrpc":"2.0","method":"echo","params":[],"id":7}`, http.StatusOK, `{"jsonrpc":"2.0","error":{"code":-32603,"message":"no params"},"id":7}`},
+		{"method not found", `{"jsonrpc":"2.0","method":"unknown","id":8}`, http.StatusOK, `{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":8}`},
+		{"parse error", `{"jsonrpc":"2.0","method"`, http.StatusOK, `{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`},
+		{"invalid request", `{"jsonrpc":"1.0","method":"eth_blockNumber","id":9}`, http.StatusOK, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":9}`},
+		{"empty batch", `[]`, http.StatusOK, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`},
+		{"notification", `{"jsonrpc":"2.0","method":"eth_blockNumber"}`, http.StatusNoContent, ``},
+		{
+			"batch",
+			`[{"jsonrpc":"2.0","method":"eth_blockNumber","id":1},{"jsonrpc":"2.0","method":"eth_blockNumber"},1,{"jsonrpc":"2.0","method":"unknown","id":"x"}]`,
+			http.StatusOK,
+			`[{"jsonrpc":"2.0","result":"0x10","id":1},{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null},{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":"x"}]`,
+		},
+		{"batch of notifications", `[{"jsonrpc":"2.0","method":"eth_blockNumber"}]`, http.StatusNoContent, ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tc.Post("https://example.com/rpc", "application/json", strings.NewReader(tt.req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				res.Body.Close()
+			})
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := res.StatusCode; got != tt.wantStatus {
+				t.Errorf("got %v\nwant %v", got, tt.wantStatus)
+			}
+			if got := string(body); got != tt.want {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+	if got := len(rpc.Requests()); got != len(tests) {
+		t.Errorf("got %v\nwant %v", got, len(tests))
+	}
+}
@@ -3,12 +3,22 @@ package httpstub
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"os"
+	"strings"
+	"sync"
 
+	"github.com/pb33f/libopenapi"
 	validator "github.com/pb33f/libopenapi-validator"
 	vconfig "github.com/pb33f/libopenapi-validator/config"
+	verrors "github.com/pb33f/libopenapi-validator/errors"
+	"github.com/pb33f/libopenapi-validator/paths"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
 )
 
 var _ http.ResponseWriter = (*recorder)(nil)
@@ -44,6 +54,11 @@ func (r *recorder) WriteHeader(statusCode int) {
 	r.rw.WriteHeader(statusCode)
 }
 
+// Unwrap returns the original http.ResponseWriter for http.ResponseController.
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.rw
+}
+
 func (r *recorder) toResponse() *http.Response {
 	return &http.Response{
 		Status:     http.StatusText(r.statusCode),
@@ -53,64 +68,173 @@ func (r *recorder) toResponse() *http.Response {
 	}
 }
 
+// openAPI3Spec is OpenAPI v3 Document mounted on the router.
+type openAPI3Spec struct {
+	// host is the host the document is mounted on (empty matches any host)
+	host string
+	// prefix is the base path the document is mounted under
+	prefix string
+	doc    libopenapi.Document
+	model  *v3.Document
+	// validators is the pool of validators of the document.
+	// Each request is validated by its own validator so that validators are not shared between goroutines.
+	validators sync.Pool
+}
+
+// newOpenAPI3SpecWithValidator returns openAPI3Spec whose validator pool is seeded with v.
+func newOpenAPI3SpecWithValidator(host, prefix string, doc libopenapi.Document, model *v3.Document, v validator.Validator) *openAPI3Spec {
+	s := &openAPI3Spec{
+		host:   host,
+		prefix: prefix,
+		doc:    doc,
+		model:  model,
+	}
+	s.validators.New = func() any {
+		// the model is built once and shared by validators (read only)
+		vv := validator.NewValidatorFromV3Model(s.model, vconfig.WithSchemaCache(nil))
+		vv.SetDocument(s.doc)
+		return vv
+	}
+	s.validators.Put(v)
+	return s
+}
+
+// match reports whether the request is for the document and returns the length of the matched prefix.
+func (s *openAPI3Spec) match(r *http.Request) (int, bool) {
+	if s.host != "" {
+		host := r.Host
+		if !strings.Contains(s.host, ":") {
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+		}
+		if !strings.EqualFold(host, s.host) {
+			return 0, false
+		}
+	}
+	if s.prefix == "" {
+		return 0, true
+	}
+	if r.URL.Path != s.prefix && !strings.HasPrefix(r.URL.Path, s.prefix+"/") {
+		return 0, false
+	}
+	return len(s.prefix), true
+}
+
+// request returns the request to the document, whose path is stripped of prefix.
+func (s *openAPI3Spec) request(r *http.Request) *http.Request {
+	if s.prefix == "" {
+		return r
+	}
+	r2 := cloneReq(r)
+	r2.URL.Path = strings.TrimPrefix(r.URL.Path, s.prefix)
+	if r2.URL.Path == "" {
+		r2.URL.Path = "/"
+	}
+	r2.URL.RawPath = ""
+	return r2
+}
+
+// acquireValidator returns a validator of the document which is not used by other goroutines.
+func (s *openAPI3Spec) acquireValidator() validator.Validator {
+	return s.validators.Get().(validator.Validator)
+}
+
+// releaseValidator returns the validator to the pool unless it reported validation errors.
+// Validators which reported errors are dropped since they may keep the state of the failed validation.
+// ref: https://github.com/k1LoW/runn/issues/882
+func (s *openAPI3Spec) releaseValidator(v validator.Validator, failed bool) {
+	if failed {
+		return
+	}
+	s.validators.Put(v)
+}
+
+// findOpenAPI3Spec finds the document mounted for the request and returns it with the request to the document.
+// Documents mounted on the host are preferred, then documents mounted under the longer prefix.
+func (rt *Router) findOpenAPI3Spec(r *http.Request) (*openAPI3Spec, *http.Request) {
+	var (
+		found *openAPI3Spec
+		score = -1
+	)
+	for _, s := range rt.openAPI3Specs {
+		n, ok := s.match(r)
+		if !ok {
+			continue
+		}
+		if s.host != "" {
+			n += 1 << 16
+		}
+		if n > score {
+			found, score = s, n
+		}
+	}
+	if found == nil {
+		return nil, nil
+	}
+	return found, found.request(r)
+}
+
 func (rt *Router) setOpenApi3Vaildator() error {
 	rt.t.Helper()
 	rt.mu.Lock()
 	defer rt.mu.Unlock()
-	if rt.openAPI3Doc == nil {
+	if len(rt.openAPI3Specs) == 0 {
 		return nil
 	}
 	mw := func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
-			v := rt.openAPI3Validator
-			if !rt.skipValidateRequest {
-				_, errs := v.ValidateHttpRequest(r)
+			spec, sr := rt.findOpenAPI3Spec(r)
+			if spec == nil {
+				// no document is mounted for the request
+				next.ServeHTTP(w, r)
+				return
+			}
+			validateRequest, validateResponse := !rt.skipValidateRequest, !rt.skipValidateResponse
+			if m, ok := r.Context().Value(matcherKey{}).(*matcher); ok {
+				m.mu.RLock()
+				switch m.validation {
+				case validationNone:
+					validateRequest, validateResponse = false, false
+				case validationRequestOnly:
+					validateRequest, validateResponse = true, false
+				}
+				m.mu.RUnlock()
+			}
+			if !validateRequest && !validateResponse {
+				next.ServeHTTP(w, r)
+				return
+			}
+			v := spec.acquireValidator()
+			failed := false
+			defer func() {
+				spec.releaseValidator(v, failed)
+			}()
+			if validateRequest {
+				_, errs := v.ValidateHttpRequest(sr)
 				if len(errs) > 0 {
+					failed = true
 					// mark that request validation failed to avoid duplicate logs in response validation
 					r = r.WithContext(context.WithValue(r.Context(), openapi3ValidationErrorKey{}, true))
-					{
-						// renew validator (workaround)
-						// ref: https://github.com/k1LoW/runn/issues/882
-						vv, errrs := validator.NewValidator(rt.openAPI3Doc, vconfig.WithSchemaCache(nil))
-						if len(errrs) > 0 {
-							rt.t.Errorf("failed to renew validator: %v", errors.Join(errrs...))
-							return
-						}
-						rt.openAPI3Validator = vv
-						v = rt.openAPI3Validator
-					}
-					var err error
-					for _, e := range errs {
-						err = errors.Join(err, e)
+					if rt.rejectInvalidRequestStatus != 0 {
+						writeValidationProblem(w, r, rt.rejectInvalidRequestStatus, errs)
+						return
 					}
-					rt.t.Errorf("failed to validate response: %v", err)
+					rt.reportValidationErrors("failed to validate request: %v", errs)
 				}
 			}
 			rec := newRecorder(w)
 			next.ServeHTTP(rec, r)
 
-			if !rt.skipValidateResponse {
+			if validateResponse {
 				// if request validation already failed, avoid duplicate response validation logging
 				if r.Context().Value(openapi3ValidationErrorKey{}) != nil {
 					return
 				}
-				_, errs := v.ValidateHttpResponse(r, rec.toResponse())
+				_, errs := v.ValidateHttpResponse(sr, rec.toResponse())
 				if len(errs) > 0 {
-					{
-						// renew validator (workaround)
-						// ref: https://github.com/k1LoW/runn/issues/882
-						vv, errrs := validator.NewValidator(rt.openAPI3Doc, vconfig.WithSchemaCache(nil))
-						if len(errrs) > 0 {
-							rt.t.Errorf("failed to renew validator: %v", errors.Join(errrs...))
-							return
-						}
-						rt.openAPI3Validator = vv
-					}
-					var err error
-					for _, e := range errs {
-						err = errors.Join(err, e)
-					}
-					rt.t.Errorf("failed to validate response: %v", err)
+					failed = true
+					rt.reportValidationErrors("failed to validate response: %v", errs)
 				}
 			}
 		}
@@ -118,3 +242,206 @@ func (rt *Router) setOpenApi3Vaildator() error {
 	rt.middlewares = append(rt.middlewares, mw)
 	return nil
 }
+
+// reportValidationErrors reports validation errors as test errors, or warnings with ValidationWarnings.
+// Warnings are written to ValidationWarningsOutput, or logged via TB.Logf (os.Stderr when TB has no Logf).
+func (rt *Router) reportValidationErrors(format string, errs []*verrors.ValidationError) {
+	var err error
+	for _, e := range errs {
+		err = errors.Join(err, e)
+	}
+	if rt.validationWarnings {
+		w := rt.validationWarningsOutput
+		if w == nil {
+			if l, ok := rt.t.(interface{ Logf(string, ...any) }); ok {
+				l.Logf("httpstub warning: "+format, err)
+				return
+			}
+			// TB without Logf
+			w = os.Stderr
+		}
+		rt.mu.Lock()
+		defer rt.mu.Unlock()
+		_, _ = fmt.Fprintf(w, "httpstub warning: "+format+"\n", err)
+		return
+	}
+	rt.t.Errorf(format, err)
+}
+
+type validationMode int
+
+const (
+	// validationDefault follows SkipValidateRequest and SkipValidateResponse of the router
+	validationDefault validationMode = iota
+	validationNone
+	validationRequestOnly
+)
+
+// SkipValidation skips validation of requests and responses matched by the matcher with OpenAPI Document.
+// It is useful for negative tests sending invalid requests deliberately.
+func (m *matcher) SkipValidation() *matcher {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.validation = validationNone
+	return m
+}
+
+// ValidateRequestOnly validates only requests matched by the matcher with OpenAPI Document, regardless of SkipValidateRequest and SkipValidateResponse.
+func (m *matcher) ValidateRequestOnly() *matcher {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.validation = validationRequestOnly
+	return m
+}
+
+// validationProblem is a problem details (RFC 9457) document of validation errors.
+type validationProblem struct {
+	Type     string                     `json:"type"`
+	Title    string                     `json:"title"`
+	Status   int                        `json:"status"`
+	Detail   string                     `json:"detail"`
+	Instance string                     `json:"instance"`
+	Errors   []*verrors.ValidationError `json:"errors"`
+}
+
+// writeValidationProblem writes application/problem+json response listing validation errors.
+func writeValidationProblem(w http.ResponseWriter, r *http.Request, status int, errs []*verrors.ValidationError) {
+	p := validationProblem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   fmt.Sprintf("request validation failed with %d error(s)", len(errs)),
+		Instance: r.URL.Path,
+		Errors:   errs,
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	_, _ = w.Write(b)
+}
+
+// Operation create request matcher using operationId of OpenAPI v3 Document.
+// The matcher matches the method and the templated path of the operation (servers in the document are honored).
+func (rt *Router) Operation(operationID string) *matcher {
+	rt.t.Helper()
+	fn, op, err := rt.operationMatchFunc(operationID)
+	if err != nil {
+		rt.t.Fatalf("httpstub error: %v", err)
+		fn = func(_ *http.Request) bool { return false }
+	}
+	m := &matcher{
+		operation:  op,
+		matchFuncs: []matchFunc{fn},
+		router:     rt,
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.addMatcher(m)
+	return m
+}
+
+func (rt *Router) operationMatchFunc(operationID string) (matchFunc, *v3.Operation, error) {
+	if len(rt.openAPI3Specs) == 0 {
+		return nil, nil, errors.New("no OpenAPI v3 document is set")
+	}
+	for _, spec := range rt.openAPI3Specs {
+		method, path, op, ok := findOperation(spec.model, operationID)
+		if !ok {
+			continue
+		}
+		validationOpts := &vconfig.ValidationOptions{RegexCache: &sync.Map{}}
+		return func(r *http.Request) bool {
+			if !strings.EqualFold(r.Method, method) {
+				return false
+			}
+			s, sr := rt.findOpenAPI3Spec(r)
+			if s != spec {
+				return false
+			}
+			pathItem, _, pathValue := paths.FindPath(sr, spec.model, validationOpts)
+			return pathItem != nil && pathValue == path
+		}, op, nil
+	}
+	return nil, nil, fmt.Errorf("operationId not found in OpenAPI v3 document: %s", operationID)
+}
+
+// checkStub checks that the matcher corresponds to an operation of OpenAPI v3 Documents and the status code is declared for it.
+func (m *matcher) checkStub(status int) error {
+	if len(m.router.openAPI3Specs) == 0 {
+		return nil
+	}
+	if m.operation != nil {
+		if !declaredStatus(m.operation.Responses, status) {
+			return fmt.Errorf("status code %d is not declared for operation %s", status, m.operation.OperationId)
+		}
+		return nil
+	}
+	if m.path == "" || strings.Contains(m.path, "*") {
+		return nil
+	}
+	var (
+		ops       []*v3.Operation
+		pathFound bool
+	)
+	validationOpts := &vconfig.ValidationOptions{RegexCache: &sync.Map{}}
+	for _, spec := range m.router.openAPI3Specs {
+		p := m.path
+		if spec.prefix != "" {
+			var ok bool
+			if p, ok = strings.CutPrefix(p, spec.prefix); !ok || (p != "" && !strings.HasPrefix(p, "/")) {
+				continue
+			}
+		}
+		r, err := http.NewRequest(http.MethodGet, p, nil)
+		if err != nil {
+			return err
+		}
+		if m.method != "" {
+			r.Method = m.method
+		}
+		pathItem, _, _ := paths.FindPath(r, spec.model, validationOpts)
+		if pathItem == nil {
+			continue
+		}
+		pathFound = true
+		for method, op := range pathItem.GetOperations().FromOldest() {
+			if m.method == "" || strings.EqualFold(method, m.method) {
+				ops = append(ops, op)
+			}
+		}
+	}
+	switch {
+	case !pathFound:
+		return fmt.Errorf("path %s is not found in OpenAPI v3 document", m.path)
+	case len(ops) == 0:
+		return fmt.Errorf("operation %s %s is not found in OpenAPI v3 document", m.method, m.path)
+	}
+	for _, op := range ops {
+		if declaredStatus(op.Responses, status) {
+			return nil
+		}
+	}
+	if m.method == "" {
+		return fmt.Errorf("status code %d is not declared for operations of path %s", status, m.path)
+	}
+	return fmt.Errorf("status code %d is not declared for operation %s %s", status, m.method, m.path)
+}
+
+// findOperation finds the operation by operationId and returns its method and templated path.
+func findOperation(doc *v3.Document, operationID string) (string, string, *v3.Operation, bool) {
+	if doc.Paths == nil {
+		return "", "", nil, false
+	}
+	for path, pathItem := range doc.Paths.PathItems.FromOldest() {
+		for method, op := range pathItem.GetOperations().FromOldest() {
+			if op.OperationId == operationID {
+				return strings.ToUpper(method), path, op, true
+			}
+		}
+	}
+	return "", "", nil, false
+}
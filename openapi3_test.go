@@ -1,9 +1,14 @@
 package httpstub
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/golang/mock/gomock"
@@ -46,6 +51,161 @@ func TestOpenAPI3(t *testing.T) {
 	}
 }
 
+func TestOpenAPI3ConcurrentValidation(t *testing.T) {
+	const n = 50
+	ctrl := gomock.NewController(t)
+	mockTB := mock_httpstub.NewMockTB(ctrl)
+	mockTB.EXPECT().Helper().AnyTimes()
+	// only invalid requests are reported, and valid requests are not affected by them
+	mockTB.EXPECT().Errorf(gomock.Any(), gomock.Any()).Times(n)
+	rt := NewRouter(mockTB, OpenApi3("testdata/openapi3.yml"))
+	rt.Method(http.MethodPost).Path("/api/v1/users").Header("Content-Type", "application/json").ResponseString(http.StatusCreated, `{"name":"alice"}`)
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	tc := ts.Client()
+	var wg sync.WaitGroup
+	for i := range 2 * n {
+		wg.Go(func() {
+			body := `{"username": "alice", "password": "passw0rd"}`
+			if i%2 == 0 {
+				body = `{"invalid": "alice", "req": "passw0rd"}`
+			}
+			req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/users", strings.NewReader(body))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			req.Header.Set("Content-Type", "application/json")
+			res, err := tc.Do(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			res.Body.Close()
+		})
+	}
+	wg.Wait()
+}
+
+func TestMatcherValidation(t *testing.T) {
+	const (
+		validReq   = `{"username": "alice", "password": "passw0rd"}`
+		invalidReq = `{"invalid": "alice", "req": "passw0rd"}`
+	)
+	tests := []struct {
+		name       string
+		opts       []Option
+		validation func(m *matcher) *matcher
+		body       string
+		status     int
+		wantErrs   int
+	}{
+		{"default", nil, func(m *matcher) *matcher { return m }, invalidReq, http.StatusCreated, 1},
+		{"skip invalid request", nil, func(m *matcher) *matcher { return m.SkipValidation() }, invalidReq, http.StatusCreated, 0},
+		{"skip invalid response", nil, func(m *matcher) *matcher { return m.SkipValidation() }, validReq, http.StatusTeapot, 0},
+		{"request only with invalid response", nil, func(m *matcher) *matcher { return m.ValidateRequestOnly() }, validReq, http.StatusTeapot, 0},
+		{"request only with invalid request", nil, func(m *matcher) *matcher { return m.ValidateRequestOnly() }, invalidReq, http.StatusCreated, 1},
+		{"request only overrides router", []Option{SkipValidateRequest(true)}, func(m *matcher) *matcher { return m.ValidateRequestOnly() }, invalidReq, http.StatusCreated, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockTB := mock_httpstub.NewMockTB(ctrl)
+			mockTB.EXPECT().Helper().AnyTimes()
+			mockTB.EXPECT().Errorf(gomock.Any(), gomock.Any()).Times(tt.wantErrs)
+			rt := NewRouter(mockTB, append([]Option{OpenApi3("testdata/openapi3.yml")}, tt.opts...)...)
+			tt.validation(rt.Method(http.MethodPost).Path("/api/v1/users")).ResponseString(tt.status, "")
+			ts := rt.Server()
+			t.Cleanup(func() {
+				ts.Close()
+			})
+			res, err := ts.Client().Post(ts.URL+"/api/v1/users", "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+		})
+	}
+}
+
+func TestValidateAfterInvalidRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockTB := mock_httpstub.NewMockTB(ctrl)
+	mockTB.EXPECT().Helper().AnyTimes()
+	// only the invalid request is reported
+	mockTB.EXPECT().Errorf("failed to validate request: %v", gomock.Any()).Times(1)
+	rt := NewRouter(mockTB, OpenApi3("testdata/openapi3.yml"))
+	rt.Method(http.MethodPost).Path("/api/v1/users").ResponseString(http.StatusCreated, "")
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	for _, body := range []string{
+		`{"invalid": "alice"}`,
+		`{"username": "alice", "password": "passw0rd"}`,
+		`{"username": "bob", "password": "passw0rd"}`,
+	} {
+		res, err := ts.Client().Post(ts.URL+"/api/v1/users", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+}
+
+func TestReleaseValidator(t *testing.T) {
+	rt := NewRouter(t, OpenApi3("testdata/openapi3.yml"))
+	spec := rt.openAPI3Specs[0]
+	v := spec.acquireValidator()
+	// the validator which reported errors is dropped
+	spec.releaseValidator(v, true)
+	if got := spec.acquireValidator(); got == v {
+		t.Error("got the validator which reported errors")
+	}
+}
+
+func TestValidationWarnings(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockTB := mock_httpstub.NewMockTB(ctrl)
+	mockTB.EXPECT().Helper().AnyTimes()
+	mockTB.EXPECT().Logf("httpstub warning: failed to validate request: %v", gomock.Any()).Times(1)
+	rt := NewRouter(mockTB, OpenApi3("testdata/openapi3.yml"), ValidationWarnings(true))
+	rt.Method(http.MethodPost).Path("/api/v1/users").ResponseString(http.StatusCreated, "")
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	res, err := ts.Client().Post(ts.URL+"/api/v1/users", "application/json", strings.NewReader(`{"invalid": "alice"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+}
+
+func TestValidationWarningsOutput(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockTB := mock_httpstub.NewMockTB(ctrl)
+	mockTB.EXPECT().Helper().AnyTimes()
+	// warnings are not logged via TB.Logf
+	buf := new(bytes.Buffer)
+	rt := NewRouter(mockTB, OpenApi3("testdata/openapi3.yml"), ValidationWarnings(true), ValidationWarningsOutput(buf))
+	rt.Method(http.MethodPost).Path("/api/v1/users").ResponseString(http.StatusCreated, "")
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	res, err := ts.Client().Post(ts.URL+"/api/v1/users", "application/json", strings.NewReader(`{"invalid": "alice"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if got := buf.String(); !strings.HasPrefix(got, "httpstub warning: failed to validate request: ") {
+		t.Errorf("got %v\nwant %v", got, "httpstub warning: failed to validate request: ...")
+	}
+}
+
 func TestOpenAPI3_1(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -115,6 +275,300 @@ func TestSkipCircularReferenceCheck(t *testing.T) {
 	}
 }
 
+func TestRejectInvalidRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		req        *http.Request
+		wantStatus int
+		wantErrors bool
+	}{
+		{"valid req", 0, newRequest(t, http.MethodPost, "/api/v1/users", `{"username": "alice", "password": "passw0rd"}`), http.StatusCreated, false},
+		{"invalid req", 0, newRequest(t, http.MethodPost, "/api/v1/users", `{"invalid": "alice", "req": "passw0rd"}`), http.StatusBadRequest, true},
+		{"invalid req with status", http.StatusUnprocessableEntity, newRequest(t, http.MethodPost, "/api/v1/users", `{"invalid": "alice", "req": "passw0rd"}`), http.StatusUnprocessableEntity, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := NewRouter(t, OpenApi3("testdata/openapi3.yml"), RejectInvalidRequest(tt.status))
+			rt.Method(http.MethodPost).Path("/api/v1/users").ResponseString(http.StatusCreated, ``)
+			ts := rt.Server()
+			t.Cleanup(func() {
+				ts.Close()
+			})
+			tc := ts.Client()
+			res, err := tc.Do(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				res.Body.Close()
+			})
+			if got := res.StatusCode; got != tt.wantStatus {
+				t.Errorf("got %v\nwant %v", got, tt.wantStatus)
+			}
+			if !tt.wantErrors {
+				return
+			}
+			if got := res.Header.Get("Content-Type"); got != "application/problem+json" {
+				t.Errorf("got %v\nwant %v", got, "application/problem+json")
+			}
+			b, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var p struct {
+				Status int              `json:"status"`
+				Errors []map[string]any `json:"errors"`
+			}
+			if err := json.Unmarshal(b, &p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Status != tt.wantStatus {
+				t.Errorf("got %v\nwant %v", p.Status, tt.wantStatus)
+			}
+			if len(p.Errors) == 0 {
+				t.Error("want validation errors")
+			}
+			for _, e := range p.Errors {
+				if e["message"] == "" {
+					t.Errorf("got %v\nwant message", e)
+				}
+			}
+		})
+	}
+}
+
+func TestRejectInvalidRequestInvalidStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockTB := mock_httpstub.NewMockTB(ctrl)
+	mockTB.EXPECT().Helper().AnyTimes()
+	mockTB.EXPECT().Fatal(gomock.Any())
+	_ = NewRouter(mockTB, OpenApi3("testdata/openapi3.yml"), RejectInvalidRequest(http.StatusOK))
+}
+
+func TestOperation(t *testing.T) {
+	rt := NewRouter(t, OpenApi3("testdata/openapi3.yml"))
+	rt.Operation("getUser").Header("Content-Type", "application/json").ResponseString(http.StatusOK, `{"data":{"username":"alice"}}`)
+	rt.Operation("listUsers").Header("Content-Type", "application/json").ResponseString(http.StatusOK, `[{"username":"alice"}]`)
+	rt.Operation("createUser").ResponseString(http.StatusCreated, ``)
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	tc := ts.Client()
+
+	tests := []struct {
+		req  *http.Request
+		want int
+	}{
+		{newRequest(t, http.MethodGet, "https://example.com/api/v1/users/1", ""), http.StatusOK},
+		{newRequest(t, http.MethodGet, "https://example.com/api/v1/users", ""), http.StatusOK},
+		{newRequest(t, http.MethodPost, "https://example.com/api/v1/users", `{"username": "alice", "password": "passw0rd"}`), http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s", tt.req.Method, tt.req.URL.Path), func(t *testing.T) {
+			res, err := tc.Do(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				res.Body.Close()
+			})
+			if got := res.StatusCode; got != tt.want {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+	if got := len(rt.matchers[0].Requests()); got != 1 {
+		t.Errorf("got %v\nwant %v", got, 1)
+	}
+}
+
+func TestOperationWithBasePath(t *testing.T) {
+	rt := NewRouter(t, BasePath("/api/v1"), OpenApi3("testdata/openapi3-no-base-path.yml"))
+	rt.Operation("listUsers").Header("Content-Type", "application/json").ResponseString(http.StatusOK, `[{"username":"alice"}]`)
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	res, err := http.Get(ts.URL + "/api/v1/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		res.Body.Close()
+	})
+	if got := res.StatusCode; got != http.StatusOK {
+		t.Errorf("got %v\nwant %v", got, http.StatusOK)
+	}
+}
+
+func TestOperationNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockTB := mock_httpstub.NewMockTB(ctrl)
+	mockTB.EXPECT().Helper().AnyTimes()
+	mockTB.EXPECT().Fatalf(gomock.Any(), gomock.Any())
+	rt := NewRouter(mockTB, OpenApi3("testdata/openapi3.yml"))
+	rt.Operation("deleteUser").ResponseString(http.StatusOK, ``)
+}
+
+func TestMultipleOpenAPI3Documents(t *testing.T) {
+	rt := NewRouter(t,
+		OpenApi3At("/users-service", "testdata/openapi3-no-base-path.yml"),
+		OpenApi3At("/pets-service/", "testdata/openapi3-media-types.yml"),
+		OpenApi3OnHost("api.example.net", "testdata/openapi3.yml"),
+		DynamicResponseMode(PreferExamples),
+	)
+	rt.Operation("listUsers").Header("Content-Type", "application/json").ResponseString(http.StatusOK, `[{"username":"carol"}]`)
+	rt.ResponseDynamic(Status("2*"))
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	tc := ts.Client()
+
+	tests := []struct {
+		req             *http.Request
+		wantStatus      int
+		wantContentType string
+		want            string
+	}{
+		{newRequest(t, http.MethodGet, "https://example.com/users-service/users", ""), http.StatusOK, "application/json", `[{"username":"carol"}]`},
+		{newRequest(t, http.MethodGet, "https://example.com/users-service/ping", ""), http.StatusOK, "text/plain", "pong"},
+		{newRequest(t, http.MethodGet, "https://example.com/pets-service/version", ""), http.StatusOK, "text/plain", "v1.0.0"},
+		{newRequest(t, http.MethodGet, "https://api.example.net/api/v1/users/1", ""), http.StatusOK, "application/json", ""},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s%s", tt.req.Method, tt.req.Host, tt.req.URL.Path), func(t *testing.T) {
+			res, err := tc.Do(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				res.Body.Close()
+			})
+			if got := res.StatusCode; got != tt.wantStatus {
+				t.Errorf("got %v\nwant %v", got, tt.wantStatus)
+			}
+			if got := res.Header.Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("got %v\nwant %v", got, tt.wantContentType)
+			}
+			b, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				return
+			}
+			if got := string(b); got != tt.want {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMultipleOpenAPI3DocumentsValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     *http.Request
+		wantErr bool
+	}{
+		{"valid req", newRequest(t, http.MethodPost, "https://example.com/users-service/users", `{"username": "alice", "password": "passw0rd"}`), false},
+		{"invalid req", newRequest(t, http.MethodPost, "https://example.com/users-service/users", `{"username": "alice"}`), true},
+		{"invalid req on host", newRequest(t, http.MethodPost, "https://api.example.net/api/v1/users", `{"username": "alice"}`), true},
+		{"not mounted", newRequest(t, http.MethodPost, "https://example.com/users", `{"username": "alice"}`), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockTB := mock_httpstub.NewMockTB(ctrl)
+			mockTB.EXPECT().Helper().AnyTimes()
+			if tt.wantErr {
+				mockTB.EXPECT().Errorf(gomock.Any(), gomock.Any())
+			}
+			rt := NewRouter(mockTB, OpenApi3At("/users-service", "testdata/openapi3-no-base-path.yml"), OpenApi3OnHost("api.example.net", "testdata/openapi3.yml"))
+			rt.Method(http.MethodPost).ResponseString(http.StatusCreated, ``)
+			ts := rt.Server()
+			t.Cleanup(func() {
+				ts.Close()
+			})
+			res, err := ts.Client().Do(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+		})
+	}
+}
+
+func TestOpenApi3FS(t *testing.T) {
+	rt := NewRouter(t,
+		OpenApi3FS(os.DirFS("testdata"), "fs/openapi.yml"),
+		MapRemoteReferences("https://schemas.example.com/common/", os.DirFS("testdata/remote")),
+		Offline(),
+	)
+	rt.Path("/api/v1/users/1").ResponseDynamic(Status("200"))
+	rt.Path("/api/v1/users/2").ResponseDynamic(Status("404"))
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	tc := ts.Client()
+
+	tests := []struct {
+		req        *http.Request
+		wantStatus int
+		want       string
+	}{
+		{newRequest(t, http.MethodGet, "https://example.com/api/v1/users/1", ""), http.StatusOK, "{\n  \"id\": 1,\n  \"username\": \"alice\"\n}"},
+		{newRequest(t, http.MethodGet, "https://example.com/api/v1/users/2", ""), http.StatusNotFound, "{\n  \"message\": \"not found\"\n}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.req.URL.Path, func(t *testing.T) {
+			res, err := tc.Do(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				res.Body.Close()
+			})
+			if got := res.StatusCode; got != tt.wantStatus {
+				t.Errorf("got %v\nwant %v", got, tt.wantStatus)
+			}
+			b, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(b); got != tt.want {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOffline(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"remote document", []Option{OpenApi3("https://example.com/openapi.yml"), Offline()}},
+		{"unmapped remote reference", []Option{Offline(), OpenApi3FS(os.DirFS("testdata"), "fs/openapi.yml")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockTB := mock_httpstub.NewMockTB(ctrl)
+			mockTB.EXPECT().Helper().AnyTimes()
+			mockTB.EXPECT().Fatal(gomock.Any())
+			_ = NewRouter(mockTB, tt.opts...)
+		})
+	}
+}
+
 func newRequest(t *testing.T, method string, path string, body string) *http.Request {
 	t.Helper()
 	req, err := http.NewRequest(method, path, strings.NewReader(body))
@@ -1,12 +1,15 @@
 package httpstub
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"strings"
 
 	"github.com/pb33f/libopenapi"
@@ -19,15 +22,29 @@ type config struct {
 	useTLS                              bool
 	cacert, cert, key                   []byte
 	clientCacert, clientCert, clientKey []byte
-	openAPI3Doc                         libopenapi.Document
-	openAPI3Validator                   validator.Validator
+	openAPI3Sources                     []*openAPI3Source
+	openAPI3Specs                       []*openAPI3Spec
+	offline                             bool
+	remoteReferences                    []remoteReference
 	skipValidateRequest                 bool
 	skipValidateResponse                bool
+	rejectInvalidRequestStatus          int
+	strictStubs                         bool
+	validationWarnings                  bool
+	validationWarningsOutput            io.Writer
 	skipCircularReferenceCheck          bool
 	addr                                string
 	basePath                            string
 	seed                                int64
 	responseMode                        ResponseMode
+	chaos                               *chaosConfig
+	coverage                            *openAPICoverageConfig
+	useConstraintGenerator              bool
+	formatGenerators                    map[string]Generator
+	schemaGenerators                    map[string]Generator
+	callbackClient                      *http.Client
+	security                            *securityConfig
+	journalExchanges                    bool
 }
 
 type Option func(*config) error
@@ -35,56 +52,50 @@ type Option func(*config) error
 // OpenApi3 sets OpenAPI Document using file path.
 func OpenApi3(l string) Option {
 	return func(c *config) error {
-		var doc libopenapi.Document
-		dc := &datamodel.DocumentConfiguration{
-			AllowFileReferences:        true,
-			AllowRemoteReferences:      true,
-			SkipCircularReferenceCheck: c.skipCircularReferenceCheck,
-		}
-		switch {
-		case strings.HasPrefix(l, "https://") || strings.HasPrefix(l, "http://"):
-			// Add URL validation
-			if _, err := url.Parse(l); err != nil {
-				return fmt.Errorf("invalid URL: %w", err)
-			}
-
-			// #nosec G107 - URL is validated
-			res, err := http.Get(l)
-			if err != nil {
-				return err
-			}
-			defer res.Body.Close()
-			b, err := io.ReadAll(res.Body)
-			if err != nil {
-				return err
-			}
-			doc, err = libopenapi.NewDocumentWithConfiguration(b, dc)
-			if err != nil {
-				return err
-			}
-		default:
-			b, err := os.ReadFile(l)
-			if err != nil {
-				return err
-			}
-			doc, err = libopenapi.NewDocumentWithConfiguration(b, dc)
-			if err != nil {
-				return err
-			}
-		}
-		v, errs := validator.NewValidator(doc, vconfig.WithSchemaCache(nil))
-		if len(errs) > 0 {
-			return errors.Join(errs...)
-		}
-		if _, errs := v.ValidateDocument(); len(errs) > 0 {
-			var err error
-			for _, e := range errs {
-				err = errors.Join(err, e)
-			}
-			return err
+		c.addOpenAPI3Source(&openAPI3Source{read: func(c *config) ([]byte, error) {
+			return c.readDocument(l)
+		}})
+		return nil
+	}
+}
+
+// OpenApi3At mounts OpenAPI Document using file path under the base path prefix.
+// Requests whose path starts with prefix are validated and responded using the document with prefix stripped,
+// so that several documents can be mounted on one router (e.g. OpenApi3At("/billing", "billing.yml")).
+func OpenApi3At(prefix, l string) Option {
+	return func(c *config) error {
+		c.addOpenAPI3Source(&openAPI3Source{prefix: prefix, read: func(c *config) ([]byte, error) {
+			return c.readDocument(l)
+		}})
+		return nil
+	}
+}
+
+// OpenApi3OnHost mounts OpenAPI Document using file path on the host.
+// Requests whose Host header is host (the port is ignored unless host has it) are validated and responded using the document.
+func OpenApi3OnHost(host, l string) Option {
+	return func(c *config) error {
+		if host == "" {
+			return errors.New("empty host to mount OpenAPI Document")
 		}
-		c.openAPI3Doc = doc
-		c.openAPI3Validator = v
+		c.addOpenAPI3Source(&openAPI3Source{host: host, read: func(c *config) ([]byte, error) {
+			return c.readDocument(l)
+		}})
+		return nil
+	}
+}
+
+// OpenApi3FS sets OpenAPI Document using path in fsys (e.g. embed.FS).
+// Relative references in the document are resolved inside fsys.
+func OpenApi3FS(fsys fs.FS, p string) Option {
+	return func(c *config) error {
+		c.addOpenAPI3Source(&openAPI3Source{
+			read: func(_ *config) ([]byte, error) {
+				return fs.ReadFile(fsys, p)
+			},
+			fsys: fsys,
+			dir:  path.Dir(p),
+		})
 		return nil
 	}
 }
@@ -92,30 +103,167 @@ func OpenApi3(l string) Option {
 // OpenApi3FromData sets OpenAPI Document from bytes.
 func OpenApi3FromData(b []byte) Option {
 	return func(c *config) error {
-		dc := &datamodel.DocumentConfiguration{
-			AllowFileReferences:        true,
-			AllowRemoteReferences:      true,
-			SkipCircularReferenceCheck: c.skipCircularReferenceCheck,
+		c.addOpenAPI3Source(&openAPI3Source{read: func(_ *config) ([]byte, error) {
+			return b, nil
+		}})
+		return nil
+	}
+}
+
+// Offline forbids network access to load OpenAPI Documents and their remote references.
+// Remote references have to be mapped to local files using MapRemoteReferences.
+func Offline() Option {
+	return func(c *config) error {
+		c.offline = true
+		return nil
+	}
+}
+
+// MapRemoteReferences maps remote references (and OpenAPI Documents) whose URL starts with urlPrefix to files in fsys.
+// e.g. MapRemoteReferences("https://schemas.example.com/", os.DirFS("testdata/schemas")) resolves
+// https://schemas.example.com/common/error.yml using testdata/schemas/common/error.yml.
+func MapRemoteReferences(urlPrefix string, fsys fs.FS) Option {
+	return func(c *config) error {
+		if urlPrefix == "" {
+			return errors.New("empty URL prefix to map remote references")
+		}
+		c.remoteReferences = append(c.remoteReferences, remoteReference{prefix: urlPrefix, fsys: fsys})
+		return nil
+	}
+}
+
+// openAPI3Source is OpenAPI Document which is loaded and mounted after all options are applied,
+// so that options for loading (e.g. Offline) take effect regardless of the order of options.
+type openAPI3Source struct {
+	host   string
+	prefix string
+	read   func(c *config) ([]byte, error)
+	// fsys and dir are the file system and the directory to resolve relative references in
+	fsys fs.FS
+	dir  string
+}
+
+type remoteReference struct {
+	prefix string
+	fsys   fs.FS
+}
+
+// addOpenAPI3Source adds the source of OpenAPI Document.
+// The source already added on the same host and prefix is replaced.
+func (c *config) addOpenAPI3Source(src *openAPI3Source) {
+	src.prefix = strings.TrimSuffix(src.prefix, "/")
+	if src.prefix != "" && !strings.HasPrefix(src.prefix, "/") {
+		src.prefix = "/" + src.prefix
+	}
+	for i, s := range c.openAPI3Sources {
+		if s.host == src.host && s.prefix == src.prefix {
+			c.openAPI3Sources[i] = src
+			return
+		}
+	}
+	c.openAPI3Sources = append(c.openAPI3Sources, src)
+}
+
+// readDocument reads document using file path or URL.
+func (c *config) readDocument(l string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(l, "https://") || strings.HasPrefix(l, "http://"):
+		// Add URL validation
+		if _, err := url.Parse(l); err != nil {
+			return nil, fmt.Errorf("invalid URL: %w", err)
 		}
-		doc, err := libopenapi.NewDocumentWithConfiguration(b, dc)
+		res, err := c.fetchRemote(l)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to fetch %s: %s", l, res.Status)
+		}
+		return io.ReadAll(res.Body)
+	default:
+		return os.ReadFile(l)
+	}
+}
+
+// fetchRemote fetches the URL using the file systems mapped by MapRemoteReferences, or the network unless offline.
+func (c *config) fetchRemote(u string) (*http.Response, error) {
+	for _, r := range c.remoteReferences {
+		p, ok := strings.CutPrefix(u, r.prefix)
+		if !ok {
+			continue
+		}
+		b, err := fs.ReadFile(r.fsys, strings.TrimPrefix(p, "/"))
+		if err != nil {
+			return nil, err
 		}
-		v, errs := validator.NewValidator(doc, vconfig.WithSchemaCache(nil))
-		if len(errs) > 0 {
-			return errors.Join(errs...)
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{},
+			Body:          io.NopCloser(bytes.NewReader(b)),
+			ContentLength: int64(len(b)),
+		}, nil
+	}
+	if c.offline {
+		return nil, fmt.Errorf("network access is forbidden by Offline: %s", u)
+	}
+	// #nosec G107 - URL is validated
+	return http.Get(u)
+}
+
+// loadOpenAPI3Specs loads OpenAPI Documents of the sources.
+func (c *config) loadOpenAPI3Specs() error {
+	for _, src := range c.openAPI3Sources {
+		b, err := src.read(c)
+		if err != nil {
+			return err
 		}
-		if _, errs := v.ValidateDocument(); len(errs) > 0 {
-			var err error
-			for _, e := range errs {
-				err = errors.Join(err, e)
-			}
+		spec, err := c.newOpenAPI3Spec(src, b)
+		if err != nil {
 			return err
 		}
-		c.openAPI3Doc = doc
-		c.openAPI3Validator = v
-		return nil
+		c.openAPI3Specs = append(c.openAPI3Specs, spec)
 	}
+	return nil
+}
+
+// newOpenAPI3Spec loads OpenAPI Document from bytes to mount it on the host and prefix of src.
+func (c *config) newOpenAPI3Spec(src *openAPI3Source, b []byte) (*openAPI3Spec, error) {
+	dc := &datamodel.DocumentConfiguration{
+		AllowFileReferences:        true,
+		AllowRemoteReferences:      !c.offline || len(c.remoteReferences) > 0,
+		RemoteURLHandler:           c.fetchRemote,
+		SkipCircularReferenceCheck: c.skipCircularReferenceCheck,
+	}
+	if src.fsys != nil {
+		sub, err := fs.Sub(src.fsys, src.dir)
+		if err != nil {
+			return nil, err
+		}
+		dc.BasePath = src.dir
+		dc.LocalFS = sub
+	}
+	doc, err := libopenapi.NewDocumentWithConfiguration(b, dc)
+	if err != nil {
+		return nil, err
+	}
+	v, errs := validator.NewValidator(doc, vconfig.WithSchemaCache(nil))
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if _, errs := v.ValidateDocument(); len(errs) > 0 {
+		var err error
+		for _, e := range errs {
+			err = errors.Join(err, e)
+		}
+		return nil, err
+	}
+	v3m, err := doc.BuildV3Model()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build OpenAPI v3 model: %w", err)
+	}
+	return newOpenAPI3SpecWithValidator(src.host, src.prefix, doc, &v3m.Model, v), nil
 }
 
 // SkipValidateRequest sets whether to skip validation of HTTP request with OpenAPI Document.
@@ -134,6 +282,52 @@ func SkipValidateResponse(skip bool) Option {
 	}
 }
 
+// ValidationWarnings sets whether to report validation errors of HTTP request and response with OpenAPI Document
+// as warnings logged via TB.Logf instead of test errors. Warnings are written to os.Stderr when TB has no Logf.
+func ValidationWarnings(warn bool) Option {
+	return func(c *config) error {
+		c.validationWarnings = warn
+		return nil
+	}
+}
+
+// ValidationWarningsOutput sets io.Writer to write warnings of ValidationWarnings to instead of TB.Logf (e.g. io.Discard to silence them).
+func ValidationWarningsOutput(w io.Writer) Option {
+	return func(c *config) error {
+		if w == nil {
+			return errors.New("validation warnings output is nil")
+		}
+		c.validationWarningsOutput = w
+		return nil
+	}
+}
+
+// RejectInvalidRequest sets the router to respond to HTTP requests that fail validation with OpenAPI Document
+// using status (default: 400) and application/problem+json body listing the validation errors, instead of reporting test errors.
+func RejectInvalidRequest(status int) Option {
+	return func(c *config) error {
+		if status == 0 {
+			status = http.StatusBadRequest
+		}
+		if status < 400 || status > 599 {
+			return fmt.Errorf("invalid status for rejecting invalid request: %d", status)
+		}
+		c.rejectInvalidRequestStatus = status
+		return nil
+	}
+}
+
+// StrictStubs sets whether to check hand-written stubs against OpenAPI Document when they are registered.
+// Response of the matcher built with Path (and Method) fails the test via TB.Fatalf
+// if the path (and the method) is not an operation of the document or the status code is not declared for it.
+// Matchers built with wildcard paths or without Path are not checked.
+func StrictStubs(strict bool) Option {
+	return func(c *config) error {
+		c.strictStubs = strict
+		return nil
+	}
+}
+
 // SkipCircularReferenceCheck sets whether to skip circular reference check in OpenAPI Document.
 func SkipCircularReferenceCheck(skip bool) Option {
 	return func(c *config) error {
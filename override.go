@@ -0,0 +1,177 @@
+package httpstub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v4"
+)
+
+// maxOverrideIndex is the maximum index of the override path, which limits how far arrays are grown.
+const maxOverrideIndex = 1000
+
+// override is a value which overrides the field of the dynamic response specified by path.
+type override struct {
+	path  string
+	steps []overrideStep
+	value any
+}
+
+// overrideStep is a step of the path: the key of an object, the index of an array or any item of an array (wildcard).
+type overrideStep struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// Override overrides the field of the dynamic response specified by JSONPath-like path with value.
+// The path supports the root ($), child keys (.key or ['key']), array indexes ([0]) and wildcards ([*] or .*).
+// Missing keys are added to the response, so that fields which are not generated from the schema can be pinned.
+// Arrays shorter than the index (e.g. empty arrays generated with maxItems: 0) are grown to the index, filling the gap with null.
+// The index is limited to 1000. value is encoded as JSON, so that json tags of structs are honored.
+func Override(path string, value any) responseExampleOption {
+	return func(c *responseExampleConfig) error {
+		steps, err := parseOverridePath(path)
+		if err != nil {
+			return err
+		}
+		c.overrides = append(c.overrides, override{path: path, steps: steps, value: value})
+		return nil
+	}
+}
+
+func parseOverridePath(path string) ([]overrideStep, error) {
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return nil, fmt.Errorf("invalid override path %q: must start with $", path)
+	}
+	var steps []overrideStep
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			i := strings.IndexAny(rest, ".[")
+			if i < 0 {
+				i = len(rest)
+			}
+			key := rest[:i]
+			rest = rest[i:]
+			switch key {
+			case "":
+				return nil, fmt.Errorf("invalid override path %q: empty key", path)
+			case "*":
+				steps = append(steps, overrideStep{wildcard: true})
+			default:
+				steps = append(steps, overrideStep{key: key})
+			}
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid override path %q: unclosed bracket", path)
+			}
+			sel := rest[1:end]
+			rest = rest[end+1:]
+			switch {
+			case sel == "*":
+				steps = append(steps, overrideStep{wildcard: true})
+			case len(sel) >= 2 && (sel[0] == '\'' || sel[0] == '"') && sel[len(sel)-1] == sel[0]:
+				steps = append(steps, overrideStep{key: sel[1 : len(sel)-1]})
+			default:
+				i, err := strconv.Atoi(sel)
+				if err != nil || i < 0 {
+					return nil, fmt.Errorf("invalid override path %q: invalid index %q", path, sel)
+				}
+				if i > maxOverrideIndex {
+					return nil, fmt.Errorf("invalid override path %q: index %d exceeds %d", path, i, maxOverrideIndex)
+				}
+				steps = append(steps, overrideStep{index: i, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("invalid override path %q: unexpected %q", path, rest[0])
+		}
+	}
+	return steps, nil
+}
+
+// applyOverrides sets the values of overrides to the node.
+func applyOverrides(node *yaml.Node, overrides []override) error {
+	node = unwrapDocumentNode(node)
+	for _, o := range overrides {
+		// encode the value as JSON to honor json tags (yaml.Node.Encode uses yaml tags and lowercased field names)
+		b, err := json.Marshal(o.value)
+		if err != nil {
+			return fmt.Errorf("failed to encode value of %s: %w", o.path, err)
+		}
+		v := &yaml.Node{}
+		if err := yaml.Unmarshal(b, v); err != nil {
+			return fmt.Errorf("failed to encode value of %s: %w", o.path, err)
+		}
+		if err := setOverride(node, o.steps, unwrapDocumentNode(v)); err != nil {
+			return fmt.Errorf("failed to override %s: %w", o.path, err)
+		}
+	}
+	return nil
+}
+
+func setOverride(node *yaml.Node, steps []overrideStep, value *yaml.Node) error {
+	if len(steps) == 0 {
+		*node = *cloneYAMLNode(value)
+		return nil
+	}
+	step := steps[0]
+	switch {
+	case step.wildcard:
+		switch node.Kind {
+		case yaml.SequenceNode:
+			for _, n := range node.Content {
+				if err := setOverride(n, steps[1:], value); err != nil {
+					return err
+				}
+			}
+		case yaml.MappingNode:
+			for i := 1; i < len(node.Content); i += 2 {
+				if err := setOverride(node.Content[i], steps[1:], value); err != nil {
+					return err
+				}
+			}
+		default:
+			return errors.New("wildcard on scalar value")
+		}
+		return nil
+	case step.isIndex:
+		if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null" {
+			// null is replaced with an array to add the item
+			*node = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		}
+		if node.Kind != yaml.SequenceNode {
+			return fmt.Errorf("index [%d] on non-array value", step.index)
+		}
+		for len(node.Content) <= step.index {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"})
+		}
+		return setOverride(node.Content[step.index], steps[1:], value)
+	default:
+		if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null" {
+			// null is replaced with an object to add the key
+			*node = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("key %q on non-object value", step.key)
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == step.key {
+				return setOverride(node.Content[i+1], steps[1:], value)
+			}
+		}
+		child := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+		if err := setOverride(child, steps[1:], value); err != nil {
+			return err
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: step.key}, child)
+		return nil
+	}
+}
@@ -0,0 +1,124 @@
+package httpstub
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type overrideOwner struct {
+	FullName string   `json:"name"`
+	Aliases  []string `json:"aliases,omitempty"`
+	Secret   string   `json:"-"`
+}
+
+func TestOverride(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *http.Request
+		opts []responseExampleOption
+		mode ResponseMode
+		want any
+	}{
+		{
+			"example",
+			newRequest(t, http.MethodGet, "https://example.com/api/v1/users", ""),
+			[]responseExampleOption{Status("200"), Override("$[0].username", "carol"), Override("$[*].email", "carol@example.com")},
+			ExamplesOnly,
+			[]any{
+				map[string]any{"username": "carol", "email": "carol@example.com"},
+				map[string]any{"username": "bob", "email": "carol@example.com"},
+			},
+		},
+		{
+			"generated",
+			newRequest(t, http.MethodGet, "https://example.com/api/v1/items/1", ""),
+			[]responseExampleOption{Status("200"), Override("$.id", 1), Override("$.owner['name']", "alice"), Override("$.tags", []any{})},
+			AlwaysGenerate,
+			map[string]any{"id": float64(1), "owner": map[string]any{"name": "alice"}, "tags": []any{}},
+		},
+		{
+			"index beyond the array",
+			newRequest(t, http.MethodGet, "https://example.com/api/v1/items/1", ""),
+			[]responseExampleOption{Status("200"), Override("$.tags", []any{}), Override("$.tags[0].id", 1), Override("$.owner", nil), Override("$.owner.aliases[1]", "bob")},
+			AlwaysGenerate,
+			map[string]any{"tags": []any{map[string]any{"id": float64(1)}}, "owner": map[string]any{"aliases": []any{nil, "bob"}}},
+		},
+		{
+			"struct with json tags",
+			newRequest(t, http.MethodGet, "https://example.com/api/v1/items/1", ""),
+			[]responseExampleOption{Status("200"), Override("$.owner", overrideOwner{FullName: "alice", Aliases: []string{"al"}})},
+			AlwaysGenerate,
+			map[string]any{"owner": map[string]any{"name": "alice", "aliases": []any{"al"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := NewRouter(t, OpenApi3("testdata/openapi3-override.yml"), DynamicResponseMode(tt.mode))
+			rt.ResponseDynamic(tt.opts...)
+			ts := rt.Server()
+			t.Cleanup(func() {
+				ts.Close()
+			})
+			res, err := ts.Client().Do(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				res.Body.Close()
+			})
+			b, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got any
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if want, ok := tt.want.(map[string]any); ok {
+				// generated fields other than overridden ones are not compared
+				gotm := map[string]any{}
+				for k := range want {
+					gotm[k] = got.(map[string]any)[k]
+				}
+				got = gotm
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOverridePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    []overrideStep
+		wantErr bool
+	}{
+		{"$", nil, false},
+		{"$.data.status", []overrideStep{{key: "data"}, {key: "status"}}, false},
+		{"$.items[0].id", []overrideStep{{key: "items"}, {index: 0, isIndex: true}, {key: "id"}}, false},
+		{"$['a.b'][*]", []overrideStep{{key: "a.b"}, {wildcard: true}}, false},
+		{"$.items.*", []overrideStep{{key: "items"}, {wildcard: true}}, false},
+		{"data.status", nil, true},
+		{"$.items[", nil, true},
+		{"$.items[-1]", nil, true},
+		{"$..id", nil, true},
+		{"$.items[1000]", []overrideStep{{key: "items"}, {index: 1000, isIndex: true}}, false},
+		{"$.items[100000000]", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := parseOverridePath(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v\nwant err %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,344 @@
+package httpstub
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	validatorconfig "github.com/pb33f/libopenapi-validator/config"
+	"github.com/pb33f/libopenapi-validator/paths"
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"go.yaml.in/yaml/v4"
+)
+
+type securityConfig struct {
+	// tokens is OAuth2 (and OpenID Connect) access tokens and their granted scopes
+	tokens map[string][]string
+	// credentials is accepted credentials per security scheme name
+	credentials map[string][]string
+}
+
+type securityOption func(c *securityConfig) error
+
+// EnforceSecurity enforces the security requirements of OpenAPI v3 Document on requests.
+// Requests without credentials of any security requirement are responded with 401 (Unauthorized),
+// and requests whose OAuth2 access token lacks the required scopes are responded with 403 (Forbidden).
+// The responses of the operation for the status code are used if declared, otherwise application/problem+json is responded.
+func EnforceSecurity(opts ...securityOption) Option {
+	return func(c *config) error {
+		sc := &securityConfig{
+			credentials: map[string][]string{},
+		}
+		for _, opt := range opts {
+			if err := opt(sc); err != nil {
+				return err
+			}
+		}
+		c.security = sc
+		return nil
+	}
+}
+
+// OAuth2Tokens sets OAuth2 (and OpenID Connect) access tokens and their granted scopes.
+// Bearer tokens not in tokens are rejected with 401 (Unauthorized).
+// Without OAuth2Tokens, any bearer token is accepted with all scopes granted.
+func OAuth2Tokens(tokens map[string][]string) securityOption {
+	return func(c *securityConfig) error {
+		c.tokens = tokens
+		return nil
+	}
+}
+
+// SecurityCredentials sets credentials accepted for the security scheme named name.
+// Credentials are the API key for apiKey, "username:password" for HTTP basic and the token for HTTP bearer.
+// Without SecurityCredentials, any credential is accepted.
+func SecurityCredentials(name string, credentials ...string) securityOption {
+	return func(c *securityConfig) error {
+		if name == "" {
+			return errors.New("security scheme name must not be empty")
+		}
+		c.credentials[name] = append(c.credentials[name], credentials...)
+		return nil
+	}
+}
+
+type securityResult int
+
+const (
+	securitySatisfied securityResult = iota
+	// securityUnauthenticated is the result that the request lacks valid credentials
+	securityUnauthenticated
+	// securityForbidden is the result that the credentials lack the required scopes
+	securityForbidden
+)
+
+func (rt *Router) securityMiddleware(sc *securityConfig) middlewareFunc {
+	validationOpts := &validatorconfig.ValidationOptions{RegexCache: &sync.Map{}}
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			spec, sr := rt.findOpenAPI3Spec(r)
+			if spec == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			pathItem, _, _ := paths.FindPath(sr, spec.model, validationOpts)
+			if pathItem == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			op, ok := pathItem.GetOperations().Get(strings.ToLower(r.Method))
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			security := op.Security
+			if security == nil {
+				security = spec.model.Security
+			}
+			result, challenges, scopes := sc.check(spec.model, security, sr)
+			switch result {
+			case securityUnauthenticated:
+				for _, c := range challenges {
+					w.Header().Add("WWW-Authenticate", c)
+				}
+				rt.writeSecurityError(w, sr, op, http.StatusUnauthorized, "credentials are missing or invalid")
+			case securityForbidden:
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, strings.Join(scopes, " ")))
+				rt.writeSecurityError(w, sr, op, http.StatusForbidden, fmt.Sprintf("insufficient scope: %s", strings.Join(scopes, " ")))
+			default:
+				next.ServeHTTP(w, r)
+			}
+		}
+	}
+}
+
+// check checks the request against the security requirements.
+// Requirements are OR'd and schemes in a requirement are AND'd.
+// It returns the challenges for WWW-Authenticate header when unauthenticated, and the missing scopes when forbidden.
+func (sc *securityConfig) check(doc *v3.Document, security []*base.SecurityRequirement, r *http.Request) (securityResult, []string, []string) {
+	if len(security) == 0 {
+		return securitySatisfied, nil, nil
+	}
+	var (
+		challenges []string
+		forbidden  []string
+		result     = securityUnauthenticated
+	)
+	for _, req := range security {
+		if req == nil || req.ContainsEmptyRequirement || req.Requirements == nil || req.Requirements.Len() == 0 {
+			return securitySatisfied, nil, nil
+		}
+		reqResult := securitySatisfied
+		var missing []string
+		for name, scopes := range req.Requirements.FromOldest() {
+			var scheme *v3.SecurityScheme
+			if doc.Components != nil && doc.Components.SecuritySchemes != nil {
+				scheme = doc.Components.SecuritySchemes.GetOrZero(name)
+			}
+			res, s := sc.checkScheme(name, scheme, scopes, r)
+			switch res {
+			case securityUnauthenticated:
+				reqResult = securityUnauthenticated
+				if c := securityChallenge(scheme); c != "" && !slices.Contains(challenges, c) {
+					challenges = append(challenges, c)
+				}
+			case securityForbidden:
+				if reqResult == securitySatisfied {
+					reqResult = securityForbidden
+				}
+				missing = append(missing, s...)
+			}
+		}
+		switch reqResult {
+		case securitySatisfied:
+			return securitySatisfied, nil, nil
+		case securityForbidden:
+			// credentials are valid but the scopes are insufficient
+			result = securityForbidden
+			if forbidden == nil {
+				forbidden = missing
+			}
+		}
+	}
+	return result, challenges, forbidden
+}
+
+// checkScheme checks the request against the security scheme and returns the missing scopes when forbidden.
+func (sc *securityConfig) checkScheme(name string, scheme *v3.SecurityScheme, scopes []string, r *http.Request) (securityResult, []string) {
+	if scheme == nil {
+		return securityUnauthenticated, nil
+	}
+	switch strings.ToLower(scheme.Type) {
+	case "apikey":
+		var key string
+		switch strings.ToLower(scheme.In) {
+		case "header":
+			key = r.Header.Get(scheme.Name)
+		case "query":
+			key = r.URL.Query().Get(scheme.Name)
+		case "cookie":
+			if c, err := r.Cookie(scheme.Name); err == nil {
+				key = c.Value
+			}
+		}
+		if key == "" || !sc.accepted(name, key) {
+			return securityUnauthenticated, nil
+		}
+	case "http":
+		credential, ok := authorization(r, scheme.Scheme)
+		if !ok {
+			return securityUnauthenticated, nil
+		}
+		if strings.EqualFold(scheme.Scheme, "basic") {
+			b, err := base64.StdEncoding.DecodeString(credential)
+			if err != nil || !strings.Contains(string(b), ":") {
+				return securityUnauthenticated, nil
+			}
+			credential = string(b)
+		}
+		if !sc.accepted(name, credential) {
+			return securityUnauthenticated, nil
+		}
+	case "oauth2", "openidconnect":
+		token, ok := authorization(r, "bearer")
+		if !ok {
+			return securityUnauthenticated, nil
+		}
+		if sc.tokens == nil {
+			return securitySatisfied, nil
+		}
+		granted, ok := sc.tokens[token]
+		if !ok {
+			return securityUnauthenticated, nil
+		}
+		var missing []string
+		for _, s := range scopes {
+			if !slices.Contains(granted, s) {
+				missing = append(missing, s)
+			}
+		}
+		if len(missing) > 0 {
+			return securityForbidden, missing
+		}
+	case "mutualtls":
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			return securityUnauthenticated, nil
+		}
+	}
+	return securitySatisfied, nil
+}
+
+// accepted reports whether the credential is accepted for the security scheme named name.
+func (sc *securityConfig) accepted(name, credential string) bool {
+	credentials, ok := sc.credentials[name]
+	if !ok {
+		return true
+	}
+	return slices.Contains(credentials, credential)
+}
+
+// authorization returns the credential of Authorization header using the scheme (e.g. Bearer).
+func authorization(r *http.Request, scheme string) (string, bool) {
+	s, credential, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(s, scheme) {
+		return "", false
+	}
+	credential = strings.TrimSpace(credential)
+	return credential, credential != ""
+}
+
+// securityChallenge returns the challenge of WWW-Authenticate header for the security scheme.
+func securityChallenge(scheme *v3.SecurityScheme) string {
+	if scheme == nil {
+		return ""
+	}
+	switch strings.ToLower(scheme.Type) {
+	case "http":
+		if scheme.Scheme == "" {
+			return ""
+		}
+		s := strings.ToUpper(scheme.Scheme[:1]) + strings.ToLower(scheme.Scheme[1:])
+		return fmt.Sprintf("%s realm=%q", s, "httpstub")
+	case "oauth2", "openidconnect":
+		return fmt.Sprintf("Bearer realm=%q", "httpstub")
+	}
+	return ""
+}
+
+// securityProblem is a problem details (RFC 9457) document of the security error.
+type securityProblem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail"`
+	Instance string `json:"instance"`
+}
+
+// writeSecurityError writes the response of the operation for the status code if declared,
+// otherwise application/problem+json response.
+func (rt *Router) writeSecurityError(w http.ResponseWriter, r *http.Request, op *v3.Operation, status int, detail string) {
+	if res := declaredResponse(op, status); res != nil {
+		m := &matcher{router: rt}
+		var (
+			b           []byte
+			contentType string
+		)
+		if res.Content != nil && res.Content.Len() > 0 {
+			var (
+				node *yaml.Node
+				err  error
+			)
+			_, node, contentType, err = m.findResponseContentAuto(r, op.Responses, strconv.Itoa(status))
+			if err == nil {
+				b, err = encodeBody(node, responseSchemaProxy(op.Responses, status, contentType), contentType)
+			}
+			if err != nil {
+				rt.t.Errorf("failed to generate response for route (%v %v %v): %v", status, r.Method, r.URL.Path, err)
+				return
+			}
+		}
+		if err := m.setResponseHeaders(w, op.Responses, status); err != nil {
+			rt.t.Errorf("failed to generate response headers of route (%v %v %v): %v", status, r.Method, r.URL.Path, err)
+			return
+		}
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write(b)
+		return
+	}
+	b, err := json.Marshal(securityProblem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	_, _ = w.Write(b)
+}
+
+// declaredResponse returns the response of the operation declared for the status code.
+func declaredResponse(op *v3.Operation, status int) *v3.Response {
+	if op.Responses == nil || op.Responses.Codes == nil {
+		return nil
+	}
+	res, ok := op.Responses.Codes.Get(strconv.Itoa(status))
+	if !ok {
+		return nil
+	}
+	return res
+}
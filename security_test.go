@@ -0,0 +1,111 @@
+package httpstub
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestEnforceSecurity(t *testing.T) {
+	rt := NewRouter(t, OpenApi3("testdata/openapi3-security.yml"), EnforceSecurity(
+		OAuth2Tokens(map[string][]string{
+			"reader": {"items:read"},
+			"writer": {"items:read", "items:write"},
+		}),
+		SecurityCredentials("basicAuth", "alice:secret"),
+	))
+	rt.ResponseDynamic(Status("2*"))
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	tc := ts.Client()
+
+	tests := []struct {
+		name             string
+		method           string
+		path             string
+		header           http.Header
+		wantStatus       int
+		wantAuthenticate string
+		wantContentType  string
+		wantBody         string
+	}{
+		{"no security", http.MethodGet, "/public", nil, http.StatusOK, "", "", ""},
+		{"global security", http.MethodGet, "/me", http.Header{"Authorization": {"Bearer token"}}, http.StatusOK, "", "", ""},
+		{"missing global security", http.MethodGet, "/me", nil, http.StatusUnauthorized, `Bearer realm="httpstub"`, "application/problem+json", `{"type":"about:blank","title":"Unauthorized","status":401,"detail":"credentials are missing or invalid","instance":"/me"}`},
+		{"api key in header", http.MethodGet, "/keys", http.Header{"X-Api-Key": {"key"}}, http.StatusOK, "", "", ""},
+		{"api key in query", http.MethodGet, "/keys?api_key=key", nil, http.StatusOK, "", "", ""},
+		{"missing api key", http.MethodGet, "/keys", nil, http.StatusUnauthorized, "", "application/problem+json", ""},
+		{"api key in cookie", http.MethodGet, "/session", http.Header{"Cookie": {"session=abc"}}, http.StatusOK, "", "", ""},
+		{"missing api key in cookie", http.MethodGet, "/session", http.Header{"Cookie": {"other=abc"}}, http.StatusUnauthorized, "", "application/problem+json", ""},
+		{"basic", http.MethodGet, "/basic", http.Header{"Authorization": {"Basic YWxpY2U6c2VjcmV0"}}, http.StatusOK, "", "", ""},
+		{"invalid basic", http.MethodGet, "/basic", http.Header{"Authorization": {"Basic Ym9iOnNlY3JldA=="}}, http.StatusUnauthorized, `Basic realm="httpstub"`, "application/problem+json", ""},
+		{"oauth2", http.MethodGet, "/items", http.Header{"Authorization": {"Bearer reader"}}, http.StatusOK, "", "", ""},
+		{"unknown token", http.MethodGet, "/items", http.Header{"Authorization": {"Bearer unknown"}}, http.StatusUnauthorized, `Bearer realm="httpstub"`, "application/json", "{\n  \"message\": \"unauthorized\"\n}"},
+		{"insufficient scope", http.MethodPost, "/items", http.Header{"Authorization": {"Bearer reader"}}, http.StatusForbidden, `Bearer error="insufficient_scope", scope="items:write"`, "", ""},
+		{"sufficient scope", http.MethodPost, "/items", http.Header{"Authorization": {"Bearer writer"}}, http.StatusCreated, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			res, err := tc.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				res.Body.Close()
+			})
+			if got := res.StatusCode; got != tt.wantStatus {
+				t.Errorf("got %v\nwant %v", got, tt.wantStatus)
+			}
+			if got := res.Header.Get("WWW-Authenticate"); got != tt.wantAuthenticate {
+				t.Errorf("got %v\nwant %v", got, tt.wantAuthenticate)
+			}
+			if tt.wantContentType != "" {
+				if got := res.Header.Get("Content-Type"); got != tt.wantContentType {
+					t.Errorf("got %v\nwant %v", got, tt.wantContentType)
+				}
+			}
+			if tt.wantBody != "" {
+				b, err := io.ReadAll(res.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := string(b); got != tt.wantBody {
+					t.Errorf("got %v\nwant %v", got, tt.wantBody)
+				}
+			}
+		})
+	}
+}
+
+func TestEnforceSecurityWithoutTokens(t *testing.T) {
+	rt := NewRouter(t, OpenApi3("testdata/openapi3-security.yml"), EnforceSecurity())
+	rt.ResponseDynamic(Status("2*"))
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/items", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer any")
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		res.Body.Close()
+	})
+	if got, want := res.StatusCode, http.StatusCreated; got != want {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+}
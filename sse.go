@@ -0,0 +1,113 @@
+package httpstub
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SSEEvent is an event of Server-Sent Events.
+type SSEEvent struct {
+	// ID sets the `id` field.
+	ID string
+	// Event sets the `event` field.
+	Event string
+	// Data sets the `data` field. Multi-line data is split into multiple `data` fields.
+	Data string
+	// Retry sets the `retry` field (reconnection time).
+	Retry time.Duration
+	// Delay is the time to wait before sending the event.
+	Delay time.Duration
+}
+
+// ResponseSSE set handler which return response streaming Server-Sent Events.
+func (m *matcher) ResponseSSE(events ...SSEEvent) {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		writeSSEHeader(w)
+		for _, ev := range events {
+			if !writeSSEEvent(w, r, ev) {
+				return
+			}
+		}
+	}
+	m.handler = http.HandlerFunc(fn)
+}
+
+// ResponseSSEChannel set handler which return response streaming Server-Sent Events received from ch.
+// The response ends when ch is closed.
+func (m *matcher) ResponseSSEChannel(ch <-chan SSEEvent) {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		writeSSEHeader(w)
+		for {
+			select {
+			case ev, ok := <-ch:
+				if !ok {
+					return
+				}
+				if !writeSSEEvent(w, r, ev) {
+					return
+				}
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+	m.handler = http.HandlerFunc(fn)
+}
+
+func writeSSEHeader(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flushResponse(w)
+}
+
+// writeSSEEvent writes ev and flushes it. It returns false if the client has gone away.
+func writeSSEEvent(w http.ResponseWriter, r *http.Request, ev SSEEvent) bool {
+	if !sleepContext(r.Context(), ev.Delay) {
+		return false
+	}
+	b := new(strings.Builder)
+	if ev.ID != "" {
+		_, _ = fmt.Fprintf(b, "id: %s\n", ev.ID)
+	}
+	if ev.Event != "" {
+		_, _ = fmt.Fprintf(b, "event: %s\n", ev.Event)
+	}
+	if ev.Retry > 0 {
+		_, _ = fmt.Fprintf(b, "retry: %d\n", ev.Retry.Milliseconds())
+	}
+	for l := range strings.SplitSeq(ev.Data, "\n") {
+		_, _ = fmt.Fprintf(b, "data: %s\n", l)
+	}
+	b.WriteString("\n")
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return false
+	}
+	flushResponse(w)
+	return true
+}
+
+// flushResponse flushes buffered data to the client if w supports it.
+func flushResponse(w http.ResponseWriter) {
+	_ = http.NewResponseController(w).Flush()
+}
+
+// sleepContext waits for d. It returns false if ctx is done while waiting.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
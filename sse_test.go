@@ -0,0 +1,87 @@
+package httpstub
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestResponseSSE(t *testing.T) {
+	rt := NewRouter(t)
+	rt.Method(http.MethodGet).Path("/events").ResponseSSE(
+		SSEEvent{ID: "1", Event: "message", Data: "hello", Retry: 3 * time.Second},
+		SSEEvent{ID: "2", Data: "multi\nline", Delay: 10 * time.Millisecond},
+	)
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	tc := ts.Client()
+
+	res, err := tc.Get("https://example.com/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		res.Body.Close()
+	})
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	{
+		got := res.Header.Get("Content-Type")
+		want := "text/event-stream"
+		if got != want {
+			t.Errorf("got %v\nwant %v", got, want)
+		}
+	}
+	{
+		got := string(body)
+		want := "id: 1\nevent: message\nretry: 3000\ndata: hello\n\nid: 2\ndata: multi\ndata: line\n\n"
+		if got != want {
+			t.Errorf("got %q\nwant %q", got, want)
+		}
+	}
+}
+
+func TestResponseSSEChannel(t *testing.T) {
+	ch := make(chan SSEEvent)
+	rt := NewRouter(t)
+	rt.Method(http.MethodGet).Path("/events").ResponseSSEChannel(ch)
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	tc := ts.Client()
+
+	res, err := tc.Get("https://example.com/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		res.Body.Close()
+	})
+	br := bufio.NewReader(res.Body)
+
+	// Each event is flushed as soon as it is sent to the channel.
+	for _, want := range []string{"first", "second"} {
+		ch <- SSEEvent{Data: want}
+		got, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != "data: "+want+"\n" {
+			t.Errorf("got %q\nwant %q", got, "data: "+want+"\n")
+		}
+		if _, err := br.ReadString('\n'); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(ch)
+	if _, err := br.ReadByte(); err != io.EOF {
+		t.Errorf("got %v\nwant %v", err, io.EOF)
+	}
+}
@@ -0,0 +1,108 @@
+package httpstub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ResponseStream set handler which return response writing and flushing chunks at intervals.
+func (m *matcher) ResponseStream(chunks [][]byte, interval time.Duration) {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		flushResponse(w)
+		for i, c := range chunks {
+			if i > 0 && !sleepContext(r.Context(), interval) {
+				return
+			}
+			if !writeChunk(w, c) {
+				return
+			}
+		}
+	}
+	m.handler = http.HandlerFunc(fn)
+}
+
+// ResponseStreamChannel set handler which return response writing and flushing chunks received from ch.
+// The response ends when ch is closed.
+func (m *matcher) ResponseStreamChannel(ch <-chan []byte) {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		flushResponse(w)
+		_ = streamChannel(w, r, ch, func(c []byte) ([]byte, error) {
+			return c, nil
+		})
+	}
+	m.handler = http.HandlerFunc(fn)
+}
+
+// ResponseNDJSON set handler which return response streaming items as newline delimited JSON.
+func (m *matcher) ResponseNDJSON(items ...any) {
+	chunks := make([][]byte, 0, len(items))
+	for _, item := range items {
+		b, err := json.Marshal(item)
+		if err != nil {
+			m.router.t.Fatalf("failed to convert message: %v", err)
+		}
+		chunks = append(chunks, append(b, '\n'))
+	}
+	m.ResponseStream(chunks, 0)
+	m.handler = withNDJSONHeader(m.handler)
+}
+
+// ResponseNDJSONChannel set handler which return response streaming items received from ch as newline delimited JSON.
+// The response ends when ch is closed.
+func (m *matcher) ResponseNDJSONChannel(ch <-chan any) {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		flushResponse(w)
+		if err := streamChannel(w, r, ch, func(item any) ([]byte, error) {
+			b, err := json.Marshal(item)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert message: %w", err)
+			}
+			return append(b, '\n'), nil
+		}); err != nil {
+			m.router.t.Errorf("%v", err)
+		}
+	}
+	m.handler = withNDJSONHeader(http.HandlerFunc(fn))
+}
+
+func withNDJSONHeader(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		next.ServeHTTP(w, r)
+	}
+}
+
+// streamChannel writes values received from ch until ch is closed or the client has gone away.
+func streamChannel[T any](w http.ResponseWriter, r *http.Request, ch <-chan T, encode func(T) ([]byte, error)) error {
+	for {
+		select {
+		case v, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			c, err := encode(v)
+			if err != nil {
+				return err
+			}
+			if !writeChunk(w, c) {
+				return nil
+			}
+		case <-r.Context().Done():
+			return nil
+		}
+	}
+}
+
+// writeChunk writes c and flushes it. It returns false if the client has gone away.
+func writeChunk(w http.ResponseWriter, c []byte) bool {
+	if _, err := w.Write(c); err != nil {
+		return false
+	}
+	flushResponse(w)
+	return true
+}
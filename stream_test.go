@@ -0,0 +1,155 @@
+package httpstub
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestResponseStream(t *testing.T) {
+	rt := NewRouter(t)
+	rt.Method(http.MethodGet).Path("/export").ResponseStream([][]byte{[]byte("a,b\n"), []byte("1,2\n"), []byte("3,4\n")}, 50*time.Millisecond)
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	tc := ts.Client()
+
+	start := time.Now()
+	res, err := tc.Get("https://example.com/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		res.Body.Close()
+	})
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected streaming to take at least 100ms, got %v", elapsed)
+	}
+	{
+		got := string(body)
+		want := "a,b\n1,2\n3,4\n"
+		if got != want {
+			t.Errorf("got %q\nwant %q", got, want)
+		}
+	}
+}
+
+func TestResponseNDJSON(t *testing.T) {
+	rt := NewRouter(t)
+	rt.Method(http.MethodGet).Path("/export").ResponseNDJSON(map[string]any{"id": 1}, map[string]any{"id": 2})
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	tc := ts.Client()
+
+	res, err := tc.Get("https://example.com/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		res.Body.Close()
+	})
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	{
+		got := res.Header.Get("Content-Type")
+		want := "application/x-ndjson"
+		if got != want {
+			t.Errorf("got %v\nwant %v", got, want)
+		}
+	}
+	{
+		got := string(body)
+		want := "{\"id\":1}\n{\"id\":2}\n"
+		if got != want {
+			t.Errorf("got %q\nwant %q", got, want)
+		}
+	}
+}
+
+func TestResponseNDJSONChannel(t *testing.T) {
+	ch := make(chan any)
+	rt := NewRouter(t)
+	rt.Method(http.MethodGet).Path("/export").ResponseNDJSONChannel(ch)
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	tc := ts.Client()
+
+	res, err := tc.Get("https://example.com/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		res.Body.Close()
+	})
+	br := bufio.NewReader(res.Body)
+	for _, id := range []string{"1", "2"} {
+		ch <- map[string]string{"id": id}
+		got, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `{"id":"` + id + "\"}\n"
+		if got != want {
+			t.Errorf("got %q\nwant %q", got, want)
+		}
+	}
+	close(ch)
+	if _, err := br.ReadByte(); err != io.EOF {
+		t.Errorf("got %v\nwant %v", err, io.EOF)
+	}
+}
+
+func TestResponseStreamChannelCancel(t *testing.T) {
+	ch := make(chan []byte)
+	done := make(chan struct{})
+	rt := NewRouter(t)
+	rt.Method(http.MethodGet).Path("/export").Middleware(func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			close(done)
+		}
+	}).ResponseStreamChannel(ch)
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	tc := ts.Client()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://example.com/export", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := tc.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		res.Body.Close()
+	})
+	ch <- []byte("chunk\n")
+	br := bufio.NewReader(res.Body)
+	if _, err := br.ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("handler did not stop after the client canceled the request")
+	}
+}
@@ -0,0 +1,66 @@
+package httpstub
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mock_httpstub "github.com/k1LoW/httpstub/mock"
+)
+
+func TestStrictStubs(t *testing.T) {
+	tests := []struct {
+		name    string
+		stub    func(rt *Router)
+		wantErr string
+	}{
+		{"method and path", func(rt *Router) {
+			rt.Method(http.MethodGet).Path("/api/v1/users/1").Response(http.StatusOK, nil)
+		}, ""},
+		{"path only", func(rt *Router) {
+			rt.Path("/api/v1/users").Response(http.StatusCreated, nil)
+		}, ""},
+		{"wildcard path", func(rt *Router) {
+			rt.Path("/api/v1/*").Response(http.StatusTeapot, nil)
+		}, ""},
+		{"without path", func(rt *Router) {
+			rt.Method(http.MethodGet).Response(http.StatusTeapot, nil)
+		}, ""},
+		{"undocumented path", func(rt *Router) {
+			rt.Method(http.MethodGet).Path("/api/v1/undocumented").Response(http.StatusOK, nil)
+		}, "path /api/v1/undocumented is not found in OpenAPI v3 document"},
+		{"undocumented method", func(rt *Router) {
+			rt.Method(http.MethodDelete).Path("/api/v1/users").Response(http.StatusOK, nil)
+		}, "operation DELETE /api/v1/users is not found in OpenAPI v3 document"},
+		{"undeclared status", func(rt *Router) {
+			rt.Method(http.MethodGet).Path("/api/v1/users/1").Response(http.StatusNotFound, nil)
+		}, "status code 404 is not declared for operation GET /api/v1/users/1"},
+		{"undeclared status of path", func(rt *Router) {
+			rt.Path("/api/v1/users").ResponseString(http.StatusTeapot, "")
+		}, "status code 418 is not declared for operations of path /api/v1/users"},
+		{"undeclared status of operation", func(rt *Router) {
+			rt.Operation("listUsers").Response(http.StatusTeapot, nil)
+		}, "status code 418 is not declared for operation listUsers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			m := mock_httpstub.NewMockTB(ctrl)
+			m.EXPECT().Helper().AnyTimes()
+			if tt.wantErr != "" {
+				m.EXPECT().Fatalf("httpstub error: %v", gomock.Any()).Do(func(_ string, args ...any) {
+					if got := args[0].(error).Error(); got != tt.wantErr {
+						t.Errorf("got %v\nwant %v", got, tt.wantErr)
+					}
+				}).Times(1)
+			}
+			rt := NewRouter(m, OpenApi3("testdata/openapi3.yml"), StrictStubs(true))
+			tt.stub(rt)
+		})
+	}
+}
+
+func TestStrictStubsDisabled(t *testing.T) {
+	rt := NewRouter(t, OpenApi3("testdata/openapi3.yml"))
+	rt.Method(http.MethodGet).Path("/api/v1/undocumented").Response(http.StatusTeapot, nil)
+}
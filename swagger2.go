@@ -0,0 +1,692 @@
+package httpstub
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pb33f/libopenapi"
+	"github.com/pb33f/libopenapi/utils"
+	"go.yaml.in/yaml/v4"
+)
+
+var swagger2Operations = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+// swagger2SchemaKeys are keys of Swagger 2.0 non-body parameters, items and headers which are moved into the schema.
+var swagger2SchemaKeys = []string{
+	"type", "format", "items", "default", "maximum", "exclusiveMaximum", "minimum", "exclusiveMinimum",
+	"maxLength", "minLength", "pattern", "maxItems", "minItems", "uniqueItems", "enum", "multipleOf",
+}
+
+// swagger2NamedKeys are keys of Swagger 2.0 objects whose keys are names.
+var swagger2NamedKeys = []string{
+	"paths", "definitions", "parameters", "responses", "securityDefinitions", "properties", "headers",
+}
+
+// Swagger2 sets Swagger 2.0 (OpenAPI v2) Document using file path or URL.
+// The document is converted to OpenAPI v3 Document, so that ResponseDynamic and validation work as with OpenApi3.
+// Documents with external references are rejected since they are not supported.
+func Swagger2(l string) Option {
+	return func(c *config) error {
+		c.addOpenAPI3Source(&openAPI3Source{read: func(c *config) ([]byte, error) {
+			b, err := c.readDocument(l)
+			if err != nil {
+				return nil, err
+			}
+			return readSwagger2(b)
+		}})
+		return nil
+	}
+}
+
+// Swagger2FromData sets Swagger 2.0 (OpenAPI v2) Document from bytes.
+func Swagger2FromData(b []byte) Option {
+	return func(c *config) error {
+		c.addOpenAPI3Source(&openAPI3Source{read: func(_ *config) ([]byte, error) {
+			return readSwagger2(b)
+		}})
+		return nil
+	}
+}
+
+func readSwagger2(b []byte) ([]byte, error) {
+	v3b, err := convertSwagger2(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert Swagger 2.0 document: %w", err)
+	}
+	return v3b, nil
+}
+
+// convertSwagger2 converts Swagger 2.0 document to OpenAPI 3.0.3 document.
+func convertSwagger2(b []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("invalid document")
+	}
+	root := doc.Content[0]
+	if v := mapGet(root, "swagger"); v == nil || v.Value != "2.0" {
+		return nil, errors.New("not a Swagger 2.0 document")
+	}
+	if err := checkSwagger2Refs(root, false); err != nil {
+		return nil, err
+	}
+	// the document is checked by building the Swagger 2.0 model before conversion
+	sdoc, err := libopenapi.NewDocument(b)
+	if err != nil {
+		return nil, err
+	}
+	if sdoc.GetSpecInfo().SpecType != utils.OpenApi2 {
+		return nil, errors.New("not a Swagger 2.0 document")
+	}
+	if _, err := sdoc.BuildV2Model(); err != nil {
+		return nil, err
+	}
+	cv := &swagger2Converter{
+		root:     root,
+		consumes: stringSeq(mapGet(root, "consumes")),
+		produces: stringSeq(mapGet(root, "produces")),
+	}
+
+	out := newMapNode()
+	mapSet(out, "openapi", newStrNode("3.0.3"))
+	components := newMapNode()
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		k, v := root.Content[i].Value, root.Content[i+1]
+		switch k {
+		case "swagger", "host", "basePath", "schemes", "consumes", "produces":
+		case "info":
+			mapSet(out, k, v)
+			if servers := cv.servers(); servers != nil {
+				mapSet(out, "servers", servers)
+			}
+		case "paths":
+			mapSet(out, "paths", cv.paths(v))
+		case "definitions":
+			schemas := newMapNode()
+			for j := 0; j+1 < len(v.Content); j += 2 {
+				mapSet(schemas, v.Content[j].Value, convertSwagger2Schema(v.Content[j+1]))
+			}
+			mapSet(components, "schemas", schemas)
+		case "parameters":
+			params, requestBodies := newMapNode(), newMapNode()
+			for j := 0; j+1 < len(v.Content); j += 2 {
+				name, p := v.Content[j].Value, v.Content[j+1]
+				switch mapGetString(p, "in") {
+				case "body":
+					mapSet(requestBodies, name, cv.requestBody(p, cv.consumes))
+				case "formData":
+					// formData parameters are inlined into the request body of operations
+				default:
+					mapSet(params, name, convertSwagger2Parameter(p))
+				}
+			}
+			if len(params.Content) > 0 {
+				mapSet(components, "parameters", params)
+			}
+			if len(requestBodies.Content) > 0 {
+				mapSet(components, "requestBodies", requestBodies)
+			}
+		case "responses":
+			responses := newMapNode()
+			for j := 0; j+1 < len(v.Content); j += 2 {
+				mapSet(responses, v.Content[j].Value, cv.response(v.Content[j+1], cv.produces))
+			}
+			mapSet(components, "responses", responses)
+		case "securityDefinitions":
+			schemes := newMapNode()
+			for j := 0; j+1 < len(v.Content); j += 2 {
+				mapSet(schemes, v.Content[j].Value, convertSwagger2SecurityScheme(v.Content[j+1]))
+			}
+			mapSet(components, "securitySchemes", schemes)
+		default:
+			// security, tags, externalDocs and extensions
+			mapSet(out, k, v)
+		}
+	}
+	if len(components.Content) > 0 {
+		mapSet(out, "components", components)
+	}
+	return yaml.Marshal(out)
+}
+
+type swagger2Converter struct {
+	root     *yaml.Node
+	consumes []string
+	produces []string
+}
+
+// servers returns servers from host, basePath and schemes.
+func (cv *swagger2Converter) servers() *yaml.Node {
+	host := mapGetString(cv.root, "host")
+	basePath := mapGetString(cv.root, "basePath")
+	if host == "" && basePath == "" {
+		return nil
+	}
+	servers := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	if host == "" {
+		server := newMapNode()
+		mapSet(server, "url", newStrNode(basePath))
+		servers.Content = append(servers.Content, server)
+		return servers
+	}
+	schemes := stringSeq(mapGet(cv.root, "schemes"))
+	if len(schemes) == 0 {
+		schemes = []string{"https"}
+	}
+	for _, scheme := range schemes {
+		server := newMapNode()
+		mapSet(server, "url", newStrNode(scheme+"://"+host+basePath))
+		servers.Content = append(servers.Content, server)
+	}
+	return servers
+}
+
+func (cv *swagger2Converter) paths(paths *yaml.Node) *yaml.Node {
+	out := newMapNode()
+	for i := 0; i+1 < len(paths.Content); i += 2 {
+		path, item := paths.Content[i].Value, paths.Content[i+1]
+		if strings.HasPrefix(path, "x-") {
+			mapSet(out, path, item)
+			continue
+		}
+		pathParams := mapGet(item, "parameters")
+		outItem := newMapNode()
+		for j := 0; j+1 < len(item.Content); j += 2 {
+			k, v := item.Content[j].Value, item.Content[j+1]
+			switch {
+			case k == "parameters":
+				// merged into operations
+			case slices.Contains(swagger2Operations, k):
+				mapSet(outItem, k, cv.operation(v, pathParams))
+			default:
+				mapSet(outItem, k, v)
+			}
+		}
+		mapSet(out, path, outItem)
+	}
+	return out
+}
+
+func (cv *swagger2Converter) operation(op, pathParams *yaml.Node) *yaml.Node {
+	// global request bodies and responses are referenced only if the operation does not override consumes and produces
+	consumes, produces := cv.consumes, cv.produces
+	ownConsumes, ownProduces := mapGet(op, "consumes"), mapGet(op, "produces")
+	if ownConsumes != nil {
+		consumes = stringSeq(ownConsumes)
+	}
+	if ownProduces != nil {
+		produces = stringSeq(ownProduces)
+	}
+
+	// operation parameters override path parameters with the same name and location
+	var params []*yaml.Node
+	if pathParams != nil {
+		params = append(params, pathParams.Content...)
+	}
+	if v := mapGet(op, "parameters"); v != nil {
+		for _, p := range v.Content {
+			rp := cv.resolveParameter(p)
+			for i, pp := range params {
+				rpp := cv.resolveParameter(pp)
+				if mapGetString(rpp, "name") == mapGetString(rp, "name") && mapGetString(rpp, "in") == mapGetString(rp, "in") {
+					params = append(params[:i], params[i+1:]...)
+					break
+				}
+			}
+			params = append(params, p)
+		}
+	}
+
+	out := newMapNode()
+	var outParams []*yaml.Node
+	var requestBody *yaml.Node
+	var formParams []*yaml.Node
+	for _, p := range params {
+		rp := cv.resolveParameter(p)
+		switch mapGetString(rp, "in") {
+		case "body":
+			if ref := mapGetString(p, "$ref"); ref != "" && ownConsumes == nil {
+				requestBody = newMapNode()
+				mapSet(requestBody, "$ref", newStrNode("#/components/requestBodies/"+strings.TrimPrefix(ref, "#/parameters/")))
+			} else {
+				requestBody = cv.requestBody(rp, consumes)
+			}
+		case "formData":
+			formParams = append(formParams, rp)
+		default:
+			if ref := mapGetString(p, "$ref"); ref != "" {
+				rn := newMapNode()
+				mapSet(rn, "$ref", newStrNode(rewriteSwagger2Ref(ref)))
+				outParams = append(outParams, rn)
+				continue
+			}
+			outParams = append(outParams, convertSwagger2Parameter(p))
+		}
+	}
+	if len(formParams) > 0 {
+		requestBody = cv.formRequestBody(formParams, consumes)
+	}
+
+	for i := 0; i+1 < len(op.Content); i += 2 {
+		k, v := op.Content[i].Value, op.Content[i+1]
+		switch k {
+		case "consumes", "produces", "schemes":
+		case "parameters":
+			if len(outParams) > 0 {
+				mapSet(out, k, &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: outParams})
+			}
+			if requestBody != nil {
+				mapSet(out, "requestBody", requestBody)
+			}
+		case "responses":
+			responses := newMapNode()
+			for j := 0; j+1 < len(v.Content); j += 2 {
+				res := v.Content[j+1]
+				if ownProduces != nil {
+					res = cv.resolveResponse(res)
+				}
+				mapSet(responses, v.Content[j].Value, cv.response(res, produces))
+			}
+			mapSet(out, k, responses)
+		default:
+			mapSet(out, k, v)
+		}
+	}
+	if mapGet(op, "parameters") == nil {
+		// parameters from the path item
+		if len(outParams) > 0 {
+			mapSet(out, "parameters", &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: outParams})
+		}
+		if requestBody != nil {
+			mapSet(out, "requestBody", requestBody)
+		}
+	}
+	return out
+}
+
+// resolveParameter resolves the reference to global parameters.
+func (cv *swagger2Converter) resolveParameter(p *yaml.Node) *yaml.Node {
+	ref := mapGetString(p, "$ref")
+	if !strings.HasPrefix(ref, "#/parameters/") {
+		return p
+	}
+	if rp := mapGet(mapGet(cv.root, "parameters"), strings.TrimPrefix(ref, "#/parameters/")); rp != nil {
+		return rp
+	}
+	return p
+}
+
+// resolveResponse resolves the reference to global responses.
+func (cv *swagger2Converter) resolveResponse(res *yaml.Node) *yaml.Node {
+	ref := mapGetString(res, "$ref")
+	if !strings.HasPrefix(ref, "#/responses/") {
+		return res
+	}
+	if rr := mapGet(mapGet(cv.root, "responses"), strings.TrimPrefix(ref, "#/responses/")); rr != nil {
+		return rr
+	}
+	return res
+}
+
+func (cv *swagger2Converter) requestBody(p *yaml.Node, consumes []string) *yaml.Node {
+	if len(consumes) == 0 {
+		consumes = []string{"application/json"}
+	}
+	out := newMapNode()
+	if d := mapGet(p, "description"); d != nil {
+		mapSet(out, "description", d)
+	}
+	content := newMapNode()
+	for _, ct := range consumes {
+		mt := newMapNode()
+		if s := mapGet(p, "schema"); s != nil {
+			mapSet(mt, "schema", convertSwagger2Schema(s))
+		}
+		mapSet(content, ct, mt)
+	}
+	mapSet(out, "content", content)
+	if r := mapGet(p, "required"); r != nil {
+		mapSet(out, "required", r)
+	}
+	return out
+}
+
+// formRequestBody converts formData parameters to the request body of an object schema.
+func (cv *swagger2Converter) formRequestBody(params []*yaml.Node, consumes []string) *yaml.Node {
+	ct := "application/x-www-form-urlencoded"
+	if slices.Contains(consumes, "multipart/form-data") {
+		ct = "multipart/form-data"
+	}
+	schema := newMapNode()
+	mapSet(schema, "type", newStrNode("object"))
+	props := newMapNode()
+	var required []*yaml.Node
+	for _, p := range params {
+		name := mapGetString(p, "name")
+		mapSet(props, name, swagger2ParameterSchema(p))
+		if mapGetString(p, "required") == "true" {
+			required = append(required, newStrNode(name))
+		}
+	}
+	mapSet(schema, "properties", props)
+	if len(required) > 0 {
+		mapSet(schema, "required", &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: required})
+	}
+	mt := newMapNode()
+	mapSet(mt, "schema", schema)
+	content := newMapNode()
+	mapSet(content, ct, mt)
+	out := newMapNode()
+	mapSet(out, "content", content)
+	return out
+}
+
+func (cv *swagger2Converter) response(res *yaml.Node, produces []string) *yaml.Node {
+	if ref := mapGetString(res, "$ref"); ref != "" {
+		out := newMapNode()
+		mapSet(out, "$ref", newStrNode(rewriteSwagger2Ref(ref)))
+		return out
+	}
+	if len(produces) == 0 {
+		produces = []string{"application/json"}
+	}
+	out := newMapNode()
+	schema := mapGet(res, "schema")
+	examples := mapGet(res, "examples")
+	for i := 0; i+1 < len(res.Content); i += 2 {
+		k, v := res.Content[i].Value, res.Content[i+1]
+		switch k {
+		case "schema", "examples":
+		case "headers":
+			headers := newMapNode()
+			for j := 0; j+1 < len(v.Content); j += 2 {
+				h := v.Content[j+1]
+				hn := newMapNode()
+				if d := mapGet(h, "description"); d != nil {
+					mapSet(hn, "description", d)
+				}
+				mapSet(hn, "schema", swagger2ParameterSchema(h))
+				mapSet(headers, v.Content[j].Value, hn)
+			}
+			mapSet(out, k, headers)
+		default:
+			mapSet(out, k, v)
+		}
+	}
+	if mapGet(out, "description") == nil {
+		mapSet(out, "description", newStrNode(""))
+	}
+	if schema == nil && examples == nil {
+		return out
+	}
+	content := newMapNode()
+	for _, ct := range produces {
+		mt := newMapNode()
+		if schema != nil {
+			mapSet(mt, "schema", convertSwagger2Schema(schema))
+		}
+		mapSet(content, ct, mt)
+	}
+	if examples != nil {
+		for i := 0; i+1 < len(examples.Content); i += 2 {
+			ct, ex := examples.Content[i].Value, examples.Content[i+1]
+			mt := mapGet(content, ct)
+			if mt == nil {
+				mt = newMapNode()
+				if schema != nil {
+					mapSet(mt, "schema", convertSwagger2Schema(schema))
+				}
+				mapSet(content, ct, mt)
+			}
+			mapSet(mt, "example", ex)
+		}
+	}
+	mapSet(out, "content", content)
+	return out
+}
+
+// convertSwagger2Parameter converts a non-body parameter.
+func convertSwagger2Parameter(p *yaml.Node) *yaml.Node {
+	if ref := mapGetString(p, "$ref"); ref != "" {
+		out := newMapNode()
+		mapSet(out, "$ref", newStrNode(rewriteSwagger2Ref(ref)))
+		return out
+	}
+	out := newMapNode()
+	for i := 0; i+1 < len(p.Content); i += 2 {
+		k, v := p.Content[i].Value, p.Content[i+1]
+		if k == "collectionFormat" || slices.Contains(swagger2SchemaKeys, k) {
+			continue
+		}
+		mapSet(out, k, v)
+	}
+	in := mapGetString(p, "in")
+	switch mapGetString(p, "collectionFormat") {
+	case "multi":
+		mapSet(out, "style", newStrNode("form"))
+		mapSet(out, "explode", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "true"})
+	case "ssv":
+		mapSet(out, "style", newStrNode("spaceDelimited"))
+		mapSet(out, "explode", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "false"})
+	case "pipes":
+		mapSet(out, "style", newStrNode("pipeDelimited"))
+		mapSet(out, "explode", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "false"})
+	default:
+		// csv (default)
+		if mapGetString(p, "type") == "array" && (in == "query" || in == "formData") {
+			mapSet(out, "style", newStrNode("form"))
+			mapSet(out, "explode", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "false"})
+		}
+	}
+	mapSet(out, "schema", swagger2ParameterSchema(p))
+	return out
+}
+
+// swagger2ParameterSchema builds the schema from a non-body parameter, items or header.
+func swagger2ParameterSchema(p *yaml.Node) *yaml.Node {
+	schema := newMapNode()
+	for _, k := range swagger2SchemaKeys {
+		v := mapGet(p, k)
+		if v == nil {
+			continue
+		}
+		if k == "items" {
+			v = swagger2ParameterSchema(v)
+		}
+		mapSet(schema, k, v)
+	}
+	return convertSwagger2Schema(schema)
+}
+
+// convertSwagger2Schema converts the schema: references, x-nullable, discriminator and type file.
+func convertSwagger2Schema(n *yaml.Node) *yaml.Node {
+	n = cloneYAMLNode(n)
+	walkSwagger2Schema(n)
+	return n
+}
+
+func walkSwagger2Schema(n *yaml.Node) {
+	switch n.Kind {
+	case yaml.SequenceNode:
+		for _, c := range n.Content {
+			walkSwagger2Schema(c)
+		}
+		return
+	case yaml.MappingNode:
+	default:
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		switch {
+		case k.Value == "$ref" && v.Kind == yaml.ScalarNode:
+			v.Value = rewriteSwagger2Ref(v.Value)
+			continue
+		case k.Value == "x-nullable":
+			k.Value = "nullable"
+			continue
+		case k.Value == "discriminator" && v.Kind == yaml.ScalarNode:
+			d := newMapNode()
+			mapSet(d, "propertyName", newStrNode(v.Value))
+			n.Content[i+1] = d
+			continue
+		case k.Value == "type" && v.Value == "file":
+			v.Value = "string"
+			mapSet(n, "format", newStrNode("binary"))
+			continue
+		case k.Value == "example" || k.Value == "default" || k.Value == "enum":
+			continue
+		}
+		if k.Value == "properties" {
+			// keys of properties are property names
+			for j := 1; j < len(v.Content); j += 2 {
+				walkSwagger2Schema(v.Content[j])
+			}
+			continue
+		}
+		walkSwagger2Schema(v)
+	}
+}
+
+// checkSwagger2Refs returns an error if the document has external references, which are not supported.
+// Keys of n are names (e.g. property names) rather than keywords if names is true.
+func checkSwagger2Refs(n *yaml.Node, names bool) error {
+	switch n.Kind {
+	case yaml.SequenceNode:
+		for _, c := range n.Content {
+			if err := checkSwagger2Refs(c, false); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i].Value, n.Content[i+1]
+			switch {
+			case names:
+				if err := checkSwagger2Refs(v, false); err != nil {
+					return err
+				}
+			case k == "$ref" && v.Kind == yaml.ScalarNode:
+				if !strings.HasPrefix(v.Value, "#") {
+					return fmt.Errorf("external reference is not supported: %s", v.Value)
+				}
+			case k == "example" || k == "examples" || k == "default" || k == "enum" || strings.HasPrefix(k, "x-"):
+				// values, not definitions
+			default:
+				if err := checkSwagger2Refs(v, slices.Contains(swagger2NamedKeys, k)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func convertSwagger2SecurityScheme(s *yaml.Node) *yaml.Node {
+	out := newMapNode()
+	if d := mapGet(s, "description"); d != nil {
+		mapSet(out, "description", d)
+	}
+	switch mapGetString(s, "type") {
+	case "basic":
+		mapSet(out, "type", newStrNode("http"))
+		mapSet(out, "scheme", newStrNode("basic"))
+	case "apiKey":
+		mapSet(out, "type", newStrNode("apiKey"))
+		mapSet(out, "name", newStrNode(mapGetString(s, "name")))
+		mapSet(out, "in", newStrNode(mapGetString(s, "in")))
+	case "oauth2":
+		mapSet(out, "type", newStrNode("oauth2"))
+		flow := newMapNode()
+		for _, k := range []string{"authorizationUrl", "tokenUrl"} {
+			if v := mapGet(s, k); v != nil {
+				mapSet(flow, k, v)
+			}
+		}
+		scopes := mapGet(s, "scopes")
+		if scopes == nil {
+			scopes = newMapNode()
+		}
+		mapSet(flow, "scopes", scopes)
+		name := map[string]string{
+			"implicit":    "implicit",
+			"password":    "password",
+			"application": "clientCredentials",
+			"accessCode":  "authorizationCode",
+		}[mapGetString(s, "flow")]
+		flows := newMapNode()
+		if name != "" {
+			mapSet(flows, name, flow)
+		}
+		mapSet(out, "flows", flows)
+	default:
+		return s
+	}
+	return out
+}
+
+func rewriteSwagger2Ref(ref string) string {
+	for from, to := range map[string]string{
+		"#/definitions/": "#/components/schemas/",
+		"#/parameters/":  "#/components/parameters/",
+		"#/responses/":   "#/components/responses/",
+	} {
+		if strings.HasPrefix(ref, from) {
+			return to + strings.TrimPrefix(ref, from)
+		}
+	}
+	return ref
+}
+
+func newMapNode() *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+}
+
+func newStrNode(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+}
+
+func mapGet(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func mapGetString(n *yaml.Node, key string) string {
+	v := mapGet(n, key)
+	if v == nil || v.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return v.Value
+}
+
+func mapSet(n *yaml.Node, key string, v *yaml.Node) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			n.Content[i+1] = v
+			return
+		}
+	}
+	n.Content = append(n.Content, newStrNode(key), v)
+}
+
+func stringSeq(n *yaml.Node) []string {
+	if n == nil || n.Kind != yaml.SequenceNode {
+		return nil
+	}
+	var s []string
+	for _, c := range n.Content {
+		s = append(s, c.Value)
+	}
+	return s
+}
@@ -0,0 +1,149 @@
+package httpstub
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mock_httpstub "github.com/k1LoW/httpstub/mock"
+	"github.com/pb33f/libopenapi"
+)
+
+func TestSwagger2(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        *http.Request
+		wantStatus int
+		want       string
+		wantErr    bool
+	}{
+		{"example", newRequest(t, http.MethodGet, "https://example.com/api/v1/users?tags=a&tags=b", ""), http.StatusOK, "[\n  {\n    \"username\": \"alice\"\n  },\n  {\n    \"username\": \"bob\"\n  }\n]", false},
+		{"valid req", newRequest(t, http.MethodPost, "https://example.com/api/v1/users", `{"username": "alice", "password": "passw0rd"}`), http.StatusCreated, "", false},
+		{"invalid req", newRequest(t, http.MethodPost, "https://example.com/api/v1/users", `{"username": "alice"}`), http.StatusCreated, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockTB := mock_httpstub.NewMockTB(ctrl)
+			mockTB.EXPECT().Helper().AnyTimes()
+			if tt.wantErr {
+				mockTB.EXPECT().Errorf(gomock.Any(), gomock.Any())
+			}
+			rt := NewRouter(mockTB, Swagger2("testdata/swagger2.yml"), DynamicResponseMode(PreferExamples))
+			rt.Method(http.MethodGet).ResponseDynamic(Status("200"))
+			rt.Method(http.MethodPost).ResponseDynamic(Status("201"))
+			ts := rt.Server()
+			t.Cleanup(func() {
+				ts.Close()
+			})
+			res, err := ts.Client().Do(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				res.Body.Close()
+			})
+			if got := res.StatusCode; got != tt.wantStatus {
+				t.Errorf("got %v\nwant %v", got, tt.wantStatus)
+			}
+			if tt.want == "" {
+				return
+			}
+			b, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(b); got != tt.want {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSwagger2NotSwagger(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockTB := mock_httpstub.NewMockTB(ctrl)
+	mockTB.EXPECT().Helper().AnyTimes()
+	mockTB.EXPECT().Fatal(gomock.Any())
+	_ = NewRouter(mockTB, Swagger2("testdata/openapi3.yml"))
+}
+
+func TestConvertSwagger2(t *testing.T) {
+	b, err := os.ReadFile("testdata/swagger2.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v3b, err := convertSwagger2(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := libopenapi.NewDocument(v3b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := doc.BuildV3Model()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Model.Servers[0].URL; got != "https://example.com/api/v1" {
+		t.Errorf("got %v\nwant %v", got, "https://example.com/api/v1")
+	}
+	users := m.Model.Paths.PathItems.GetOrZero("/users")
+	if got := users.Post.RequestBody.Content.GetOrZero("application/json").Schema.GetReference(); got != "#/components/schemas/NewUser" {
+		t.Errorf("got %v\nwant %v", got, "#/components/schemas/NewUser")
+	}
+	if got := users.Get.Parameters[1].Style; got != "form" {
+		t.Errorf("got %v\nwant %v", got, "form")
+	}
+	if got := users.Get.Responses.Default.Content.GetOrZero("application/json"); got == nil {
+		t.Error("default response is not resolved")
+	}
+	user := m.Model.Paths.PathItems.GetOrZero("/users/{id}")
+	if got := len(user.Get.Parameters); got != 1 {
+		t.Errorf("got %v\nwant %v", got, 1)
+	}
+	// consumes and produces of the operation override the global ones
+	if got := user.Put.RequestBody.Content.GetOrZero("application/vnd.user+json"); got == nil || got.Schema.GetReference() != "#/components/schemas/NewUser" {
+		t.Error("consumes of the operation is not used for the referenced body parameter")
+	}
+	if got := user.Put.Responses.Codes.GetOrZero("404").Content.GetOrZero("application/vnd.user+json"); got == nil {
+		t.Error("produces of the operation is not used for the referenced response")
+	}
+	upload := m.Model.Paths.PathItems.GetOrZero("/upload")
+	file := upload.Post.RequestBody.Content.GetOrZero("multipart/form-data").Schema.Schema().Properties.GetOrZero("file").Schema()
+	if file.Format != "binary" {
+		t.Errorf("got %v\nwant %v", file.Format, "binary")
+	}
+	nickname := m.Model.Components.Schemas.GetOrZero("User").Schema().Properties.GetOrZero("nickname").Schema()
+	if nickname.Nullable == nil || !*nickname.Nullable {
+		t.Error("x-nullable is not converted")
+	}
+	if got := m.Model.Components.SecuritySchemes.GetOrZero("oauth").Flows.AuthorizationCode.TokenUrl; got != "https://example.com/oauth/token" {
+		t.Errorf("got %v\nwant %v", got, "https://example.com/oauth/token")
+	}
+}
+
+func TestConvertSwagger2ExternalReference(t *testing.T) {
+	b := []byte(`swagger: '2.0'
+info:
+  title: test spec
+  version: 0.0.1
+paths:
+  /users:
+    get:
+      responses:
+        '200':
+          description: OK
+          schema:
+            $ref: 'definitions.yml#/User'
+`)
+	_, err := convertSwagger2(b)
+	if err == nil || !strings.Contains(err.Error(), "external reference is not supported") {
+		t.Errorf("got %v\nwant external reference error", err)
+	}
+}
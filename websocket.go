@@ -0,0 +1,431 @@
+package httpstub
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket opcodes.
+const (
+	WebSocketContinuation = 0x0
+	WebSocketText         = 0x1
+	WebSocketBinary       = 0x2
+	WebSocketClose        = 0x8
+	WebSocketPing         = 0x9
+	WebSocketPong         = 0xA
+)
+
+// WebSocket close status codes.
+const (
+	WebSocketCloseNormal          = 1000
+	WebSocketCloseProtocolError   = 1002
+	WebSocketClosePolicyViolation = 1008
+)
+
+const (
+	webSocketGUID           = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	webSocketMaxPayloadSize = 32 << 20
+	webSocketCloseTimeout   = 5 * time.Second
+)
+
+// WebSocketDirection is the direction of a WebSocket frame.
+type WebSocketDirection int
+
+const (
+	// WebSocketReceived is a frame sent by the client and received by the stub.
+	WebSocketReceived WebSocketDirection = iota
+	// WebSocketSent is a frame sent by the stub to the client.
+	WebSocketSent
+)
+
+// WebSocketFrame is a WebSocket frame recorded by the stub.
+type WebSocketFrame struct {
+	Direction WebSocketDirection
+	Fin       bool
+	Opcode    int
+	Payload   []byte
+	Time      time.Time
+}
+
+type webSocketStepKind int
+
+const (
+	webSocketStepSend webSocketStepKind = iota
+	webSocketStepExpect
+	webSocketStepClose
+)
+
+type webSocketStep struct {
+	kind    webSocketStepKind
+	opcode  int
+	payload []byte
+	match   func(msg []byte) bool
+	desc    string
+}
+
+type webSocketStub struct {
+	matcher *matcher
+	steps   []webSocketStep
+	frames  []*WebSocketFrame
+	conns   map[net.Conn]struct{}
+	mu      sync.RWMutex
+}
+
+type webSocketConn struct {
+	ws   *webSocketStub
+	conn net.Conn
+	br   *bufio.Reader
+	wmu  sync.Mutex
+}
+
+// WebSocket create request matcher for WebSocket endpoint using path, and returns the scripted conversation builder.
+// The conversation steps (Send, Expect, Close) are run in order on every connection.
+func (rt *Router) WebSocket(path string) *webSocketStub {
+	// WebSocket conversations are not described by OpenAPI Document
+	m := rt.Method(http.MethodGet).Path(path).Match(isWebSocketUpgrade).SkipValidation()
+	ws := &webSocketStub{
+		matcher: m,
+		conns:   map[net.Conn]struct{}{},
+	}
+	m.Handler(ws.serveHTTP)
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.webSockets = append(rt.webSockets, ws)
+	return ws
+}
+
+// Send append step which send message to the client.
+// string and []byte are sent as text messages, other values are marshaled to JSON.
+// Steps before the first Expect are sent on connect.
+func (ws *webSocketStub) Send(msg any) *webSocketStub {
+	var b []byte
+	switch v := msg.(type) {
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		var err error
+		b, err = json.Marshal(v)
+		if err != nil {
+			ws.matcher.router.t.Fatalf("failed to convert message: %v", err)
+		}
+	}
+	return ws.addStep(webSocketStep{kind: webSocketStepSend, opcode: WebSocketText, payload: b})
+}
+
+// SendBinary append step which send binary message to the client.
+func (ws *webSocketStub) SendBinary(b []byte) *webSocketStub {
+	return ws.addStep(webSocketStep{kind: webSocketStepSend, opcode: WebSocketBinary, payload: b})
+}
+
+// Expect append step which wait for the message from the client and check that it equals msg.
+func (ws *webSocketStub) Expect(msg string) *webSocketStub {
+	return ws.addStep(webSocketStep{
+		kind: webSocketStepExpect,
+		match: func(got []byte) bool {
+			return string(got) == msg
+		},
+		desc: fmt.Sprintf("%q", msg),
+	})
+}
+
+// ExpectMatch append step which wait for the message from the client and check it using fn.
+func (ws *webSocketStub) ExpectMatch(fn func(msg []byte) bool) *webSocketStub {
+	return ws.addStep(webSocketStep{kind: webSocketStepExpect, match: fn, desc: "matching message"})
+}
+
+// Close append step which close the connection with status code and reason.
+func (ws *webSocketStub) Close(code int, reason string) {
+	ws.addStep(webSocketStep{kind: webSocketStepClose, opcode: WebSocketClose, payload: webSocketClosePayload(code, reason)})
+}
+
+// Frames returns []*WebSocketFrame sent and received by the WebSocket endpoint.
+func (ws *webSocketStub) Frames() []*WebSocketFrame {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	return ws.frames
+}
+
+// Requests returns []*http.Request (handshake requests) received by the WebSocket endpoint.
+func (ws *webSocketStub) Requests() []*http.Request {
+	return ws.matcher.Requests()
+}
+
+func (ws *webSocketStub) addStep(s webSocketStep) *webSocketStub {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.steps = append(ws.steps, s)
+	return ws
+}
+
+func (ws *webSocketStub) record(dir WebSocketDirection, fin bool, opcode int, payload []byte) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.frames = append(ws.frames, &WebSocketFrame{
+		Direction: dir,
+		Fin:       fin,
+		Opcode:    opcode,
+		Payload:   bytes.Clone(payload),
+		Time:      time.Now(),
+	})
+}
+
+func (ws *webSocketStub) closeConns() {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	for c := range ws.conns {
+		_ = c.Close()
+	}
+	ws.conns = map[net.Conn]struct{}{}
+}
+
+func (ws *webSocketStub) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	t := ws.matcher.router.t
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		http.Error(w, "invalid WebSocket handshake", http.StatusBadRequest)
+		return
+	}
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		t.Errorf("httpstub error: failed to hijack connection for WebSocket: %v", err)
+		return
+	}
+	ws.mu.Lock()
+	ws.conns[conn] = struct{}{}
+	steps := ws.steps
+	ws.mu.Unlock()
+	defer func() {
+		ws.mu.Lock()
+		delete(ws.conns, conn)
+		ws.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	h := sha1.Sum([]byte(key + webSocketGUID)) //nolint:gosec
+	res := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(h[:]) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(res)); err != nil {
+		return
+	}
+	c := &webSocketConn{ws: ws, conn: conn, br: brw.Reader}
+
+	for _, s := range steps {
+		switch s.kind {
+		case webSocketStepSend:
+			if err := c.writeFrame(s.opcode, s.payload); err != nil {
+				return
+			}
+		case webSocketStepExpect:
+			msg, err := c.readMessage()
+			if err != nil {
+				// connections closed by the client's close frame or by Router.Close are not errors
+				if !errors.Is(err, errWebSocketClosed) && !errors.Is(err, net.ErrClosed) {
+					t.Errorf("httpstub error: failed to read WebSocket message (expect %s): %v", s.desc, err)
+				}
+				return
+			}
+			if !s.match(msg) {
+				t.Errorf("httpstub error: unexpected WebSocket message\ngot %q\nwant %s", string(msg), s.desc)
+				_ = c.close(WebSocketClosePolicyViolation, "unexpected message")
+				return
+			}
+		case webSocketStepClose:
+			if err := c.writeFrame(s.opcode, s.payload); err != nil {
+				return
+			}
+			c.waitClose()
+			return
+		}
+	}
+	// Keep the connection open, recording frames, until the client closes it.
+	for {
+		if _, err := c.readMessage(); err != nil {
+			return
+		}
+	}
+}
+
+var errWebSocketClosed = errors.New("websocket connection closed by client")
+
+// readMessage reads a data message (assembling fragmented frames) while handling control frames.
+func (c *webSocketConn) readMessage() ([]byte, error) {
+	var msg []byte
+	for {
+		fin, opcode, payload, err := readWebSocketFrame(c.br)
+		if err != nil {
+			return nil, err
+		}
+		c.ws.record(WebSocketReceived, fin, opcode, payload)
+		switch opcode {
+		case WebSocketPing:
+			if err := c.writeFrame(WebSocketPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case WebSocketPong:
+			continue
+		case WebSocketClose:
+			// echo the status code back to complete the closing handshake
+			if len(payload) > 2 {
+				payload = payload[:2]
+			}
+			_ = c.writeFrame(WebSocketClose, payload)
+			return nil, errWebSocketClosed
+		}
+		msg = append(msg, payload...)
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+// waitClose waits for the close frame from the client after the stub has sent its close frame.
+func (c *webSocketConn) waitClose() {
+	_ = c.conn.SetReadDeadline(time.Now().Add(webSocketCloseTimeout))
+	for {
+		fin, opcode, payload, err := readWebSocketFrame(c.br)
+		if err != nil {
+			return
+		}
+		c.ws.record(WebSocketReceived, fin, opcode, payload)
+		if opcode == WebSocketClose {
+			return
+		}
+	}
+}
+
+func (c *webSocketConn) close(code int, reason string) error {
+	if err := c.writeFrame(WebSocketClose, webSocketClosePayload(code, reason)); err != nil {
+		return err
+	}
+	c.waitClose()
+	return nil
+}
+
+func (c *webSocketConn) writeFrame(opcode int, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.ws.record(WebSocketSent, true, opcode, payload)
+	return writeWebSocketFrame(c.conn, true, opcode, payload, false)
+}
+
+func webSocketClosePayload(code int, reason string) []byte {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code)) //nolint:gosec
+	return append(payload, reason...)
+}
+
+func isWebSocketUpgrade(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") && headerContainsToken(r.Header, "Upgrade", "websocket")
+}
+
+func headerContainsToken(h http.Header, key, token string) bool {
+	for _, v := range h.Values(key) {
+		for t := range strings.SplitSeq(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readWebSocketFrame reads a single WebSocket frame, unmasking the payload if it is masked.
+func readWebSocketFrame(r io.Reader) (bool, int, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin := head[0]&0x80 != 0
+	opcode := int(head[0] & 0x0F)
+	masked := head[1]&0x80 != 0
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > webSocketMaxPayloadSize {
+		return false, 0, nil, fmt.Errorf("websocket frame too large: %d bytes", length)
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeWebSocketFrame writes a single WebSocket frame. Frames sent by clients must be masked.
+func writeWebSocketFrame(w io.Writer, fin bool, opcode int, payload []byte, mask bool) error {
+	var b bytes.Buffer
+	first := byte(opcode & 0x0F) //nolint:gosec
+	if fin {
+		first |= 0x80
+	}
+	b.WriteByte(first)
+	var maskBit byte
+	if mask {
+		maskBit = 0x80
+	}
+	switch l := len(payload); {
+	case l < 126:
+		b.WriteByte(maskBit | byte(l))
+	case l <= 0xFFFF:
+		b.WriteByte(maskBit | 126)
+		_ = binary.Write(&b, binary.BigEndian, uint16(l))
+	default:
+		b.WriteByte(maskBit | 127)
+		_ = binary.Write(&b, binary.BigEndian, uint64(l))
+	}
+	if mask {
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		b.Write(key[:])
+		for i, c := range payload {
+			b.WriteByte(c ^ key[i%4])
+		}
+	} else {
+		b.Write(payload)
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
@@ -0,0 +1,223 @@
+package httpstub
+
+import (
+	"bufio"
+	"crypto/sha1" //nolint:gosec
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mock_httpstub "github.com/k1LoW/httpstub/mock"
+)
+
+func TestWebSocket(t *testing.T) {
+	rt := NewRouter(t)
+	rt.Method(http.MethodGet).Path("/api/v1/users/1").ResponseString(http.StatusOK, `{"name":"alice"}`)
+	ws := rt.WebSocket("/ws")
+	ws.Send("welcome").Expect("ping").Send(map[string]string{"type": "pong"}).Close(WebSocketCloseNormal, "bye")
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+
+	// REST and WebSocket are served by the same server
+	res, err := ts.Client().Get("https://example.com/api/v1/users/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+
+	c := dialWebSocket(t, ts.Listener.Addr().String(), "/ws")
+	t.Cleanup(func() {
+		_ = c.Close()
+	})
+	br := bufio.NewReader(c)
+
+	if got := readWebSocketTestMessage(t, br); got != "welcome" {
+		t.Errorf("got %v\nwant %v", got, "welcome")
+	}
+	if err := writeWebSocketFrame(c, true, WebSocketText, []byte("ping"), true); err != nil {
+		t.Fatal(err)
+	}
+	if got := readWebSocketTestMessage(t, br); got != `{"type":"pong"}` {
+		t.Errorf("got %v\nwant %v", got, `{"type":"pong"}`)
+	}
+	_, opcode, payload, err := readWebSocketFrame(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opcode != WebSocketClose {
+		t.Fatalf("got %v\nwant %v", opcode, WebSocketClose)
+	}
+	if got := int(binary.BigEndian.Uint16(payload[:2])); got != WebSocketCloseNormal {
+		t.Errorf("got %v\nwant %v", got, WebSocketCloseNormal)
+	}
+	if got := string(payload[2:]); got != "bye" {
+		t.Errorf("got %v\nwant %v", got, "bye")
+	}
+	if err := writeWebSocketFrame(c, true, WebSocketClose, payload[:2], true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := br.ReadByte(); err != io.EOF {
+		t.Errorf("got %v\nwant %v", err, io.EOF)
+	}
+
+	frames := ws.Frames()
+	var got []string
+	for _, f := range frames {
+		dir := "<"
+		if f.Direction == WebSocketSent {
+			dir = ">"
+		}
+		got = append(got, dir+string(rune('0'+f.Opcode)))
+	}
+	want := []string{">1", "<1", ">1", ">8", "<8"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+	if len(ws.Requests()) != 1 {
+		t.Errorf("got %v\nwant %v", len(ws.Requests()), 1)
+	}
+}
+
+func TestWebSocketWithOpenAPI3(t *testing.T) {
+	// WebSocket endpoints are not validated with OpenAPI Document
+	rt := NewRouter(t, OpenApi3("testdata/openapi3.yml"))
+	rt.WebSocket("/ws").Send("welcome")
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	c := dialWebSocket(t, ts.Listener.Addr().String(), "/ws")
+	t.Cleanup(func() {
+		_ = c.Close()
+	})
+	if got := readWebSocketTestMessage(t, bufio.NewReader(c)); got != "welcome" {
+		t.Errorf("got %v\nwant %v", got, "welcome")
+	}
+}
+
+func TestWebSocketUnexpectedMessage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(func() {
+		ctrl.Finish()
+	})
+	mockTB := mock_httpstub.NewMockTB(ctrl)
+	mockTB.EXPECT().Helper().AnyTimes()
+	mockTB.EXPECT().Errorf(gomock.Any(), gomock.Any())
+
+	rt := NewRouter(mockTB)
+	rt.WebSocket("/ws").Expect("hello")
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+
+	c := dialWebSocket(t, ts.Listener.Addr().String(), "/ws")
+	t.Cleanup(func() {
+		_ = c.Close()
+	})
+	br := bufio.NewReader(c)
+	if err := writeWebSocketFrame(c, true, WebSocketText, []byte("goodbye"), true); err != nil {
+		t.Fatal(err)
+	}
+	_, opcode, payload, err := readWebSocketFrame(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opcode != WebSocketClose {
+		t.Fatalf("got %v\nwant %v", opcode, WebSocketClose)
+	}
+	if got := int(binary.BigEndian.Uint16(payload[:2])); got != WebSocketClosePolicyViolation {
+		t.Errorf("got %v\nwant %v", got, WebSocketClosePolicyViolation)
+	}
+	if err := writeWebSocketFrame(c, true, WebSocketClose, payload[:2], true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebSocketPing(t *testing.T) {
+	rt := NewRouter(t)
+	ws := rt.WebSocket("/ws")
+	ts := rt.Server()
+	t.Cleanup(func() {
+		ts.Close()
+	})
+
+	c := dialWebSocket(t, ts.Listener.Addr().String(), "/ws")
+	t.Cleanup(func() {
+		_ = c.Close()
+	})
+	br := bufio.NewReader(c)
+	if err := writeWebSocketFrame(c, true, WebSocketPing, []byte("hb"), true); err != nil {
+		t.Fatal(err)
+	}
+	_, opcode, payload, err := readWebSocketFrame(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opcode != WebSocketPong || string(payload) != "hb" {
+		t.Errorf("got %v %q\nwant %v %q", opcode, payload, WebSocketPong, "hb")
+	}
+	if got := len(ws.Frames()); got != 2 {
+		t.Errorf("got %v\nwant %v", got, 2)
+	}
+}
+
+func dialWebSocket(t *testing.T, addr, path string) net.Conn {
+	t.Helper()
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = c.SetDeadline(time.Now().Add(10 * time.Second))
+	key := base64.StdEncoding.EncodeToString([]byte("httpstub-test-key"))
+	req := "GET " + path + " HTTP/1.1\r\n" +
+		"Host: " + addr + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := c.Write([]byte(req)); err != nil {
+		t.Fatal(err)
+	}
+	// read the handshake response byte by byte so that no frame data is buffered
+	var head strings.Builder
+	b := make([]byte, 1)
+	for !strings.HasSuffix(head.String(), "\r\n\r\n") {
+		if _, err := c.Read(b); err != nil {
+			t.Fatal(err)
+		}
+		head.WriteByte(b[0])
+	}
+	res, err := http.ReadResponse(bufio.NewReader(strings.NewReader(head.String())), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("got %v\nwant %v", res.StatusCode, http.StatusSwitchingProtocols)
+	}
+	h := sha1.Sum([]byte(key + webSocketGUID)) //nolint:gosec
+	if got, want := res.Header.Get("Sec-WebSocket-Accept"), base64.StdEncoding.EncodeToString(h[:]); got != want {
+		t.Fatalf("got %v\nwant %v", got, want)
+	}
+	return c
+}
+
+func readWebSocketTestMessage(t *testing.T, r io.Reader) string {
+	t.Helper()
+	_, opcode, payload, err := readWebSocketFrame(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opcode != WebSocketText {
+		t.Fatalf("got %v\nwant %v", opcode, WebSocketText)
+	}
+	return string(payload)
+}